@@ -12,17 +12,29 @@ import (
 const (
 	MessageKey string = "msg"
 	CauseKey   string = "cause"
+	StatusKey  string = "status"
+	CodeKey    string = "code"
 )
 
 // New creates a new KVError with keys and values
 func New(msg string, keysAndValues ...interface{}) error {
+	return newError(1, msg, keysAndValues...)
+}
+
+// newError creates a new KVError and captures the stack above skip callers
+// if CaptureStacks is enabled
+func newError(skip int, msg string, keysAndValues ...interface{}) *KVError {
 	keysAndValues = append([]interface{}{MessageKey, msg}, keysAndValues...)
-	return &KVError{kv: kv.ToMap(keysAndValues...)}
+	e := &KVError{kv: kv.ToMap(keysAndValues...)}
+	if capturingStacks() {
+		e.stack = callers(skip + 1)
+	}
+	return e
 }
 
 // NewCtx creates a new error with Context
 func NewCtx(msg string, ctx Context, keysAndValues ...interface{}) error {
-	return New(msg, append(keysAndValues, ctx...)...)
+	return newError(1, msg, append(keysAndValues, ctx...)...)
 }
 
 // Wrap wraps an error as a new error with keys and values
@@ -30,13 +42,38 @@ func Wrap(err error, msg string, keysAndValues ...interface{}) error {
 	if err == nil {
 		return nil
 	}
-	e := New(msg, append(keysAndValues, []interface{}{CauseKey, err}...)...)
-	return e
+	return newError(1, msg, append(keysAndValues, []interface{}{CauseKey, err}...)...)
 }
 
 // KVError is an error that contains structured keys and values
 type KVError struct {
 	kv map[string]interface{}
+	// orig is the error that the key/value pairs were added to. See Add
+	orig error
+	// stack is the call stack at creation if CaptureStacks is enabled
+	stack []uintptr
+	// sentinel is set for errors created by NewSentinel, which match errors
+	// by code. See Is
+	sentinel bool
+}
+
+// NewSentinel creates a sentinel error identified by code, which is added
+// under CodeKey. Unlike errors created by New, which only match themselves,
+// errors.Is matches a sentinel with any KVError that has the same code, such as
+// one reconstructed from the JSON of the sentinel or of an error wrapping it.
+func NewSentinel(code, msg string) error {
+	return &KVError{kv: kv.ToMap(MessageKey, msg, CodeKey, code), sentinel: true}
+}
+
+// Is reports whether target is a sentinel, see NewSentinel, with the code of
+// e. It is used by errors.Is
+func (e *KVError) Is(target error) bool {
+	t, ok := target.(*KVError)
+	if !ok || !t.sentinel {
+		return false
+	}
+	code, ok := e.kv[CodeKey].(string)
+	return ok && code == t.kv[CodeKey]
 }
 
 // KVs returns the key/value pairs associated with this error if it is a *KVError
@@ -65,6 +102,9 @@ func KVSlice(err error) []interface{} {
 // Unwrap returns the error that caused this error. This is required
 // to work with the standard library errors.Unwrap
 func (e *KVError) Unwrap() error {
+	if e.orig != nil {
+		return e.orig
+	}
 	if cause, ok := e.kv[CauseKey]; ok {
 		e, _ := cause.(error)
 		// if ok is false then e will be empty anyway so no need to check if ok
@@ -76,6 +116,9 @@ func (e *KVError) Unwrap() error {
 // Error returns the string formatted error message. This is required
 // to function as a standard library error
 func (e *KVError) Error() string {
+	if e.orig != nil {
+		return e.orig.Error()
+	}
 	base := e.Unwrap()
 	if base != nil {
 		return fmt.Sprintf("%s: %s", Message(e), base.Error())
@@ -93,17 +136,29 @@ func Message(err error) string {
 	return fmt.Sprint(msg)
 }
 
-// Add adds key/value pairs to an error and returns the error
-// WARNING: The original error is modified with this operation
+// Add returns a new error that holds err and its key/value pairs combined
+// with keyValuePairs. The key/value pairs of err are only combined if err is a
+// *KVError itself, those of a KVError wrapped by another error stay with it
+// in the chain. err itself is never modified so it is safe to Add to shared
+// errors such as sentinels. The returned error unwraps to err, so
+// errors.Is(Add(err, ...), err) holds.
 func Add(err error, keyValuePairs ...interface{}) error {
-	var kve *KVError
-	if !errors.As(err, &kve) {
-		return New(err.Error(), keyValuePairs...)
+	added := kv.ToMap(keyValuePairs...)
+
+	kve, ok := err.(*KVError)
+	if !ok {
+		added[MessageKey] = err.Error()
+		return &KVError{kv: added, orig: err}
 	}
-	for k, v := range kv.ToMap(keyValuePairs...) {
-		kve.kv[k] = v
+
+	m := make(map[string]interface{}, len(kve.kv)+len(added))
+	for k, v := range kve.kv {
+		m[k] = v
 	}
-	return kve
+	for k, v := range added {
+		m[k] = v
+	}
+	return &KVError{kv: m, orig: err}
 }
 
 // MarshalJSON implements json.Marshaler
@@ -111,6 +166,109 @@ func (e *KVError) MarshalJSON() ([]byte, error) {
 	return json.Marshal(e.kv)
 }
 
+// UnmarshalJSON implements json.Unmarshaler. It reconstructs an error from
+// the JSON written by MarshalJSON: a cause that is an object is reconstructed
+// as a KVError and any other cause as an error with its string, and an
+// integer status is restored as an int so that Status finds it.
+func (e *KVError) UnmarshalJSON(b []byte) error {
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(b, &raw); err != nil {
+		return err
+	}
+
+	m := make(map[string]interface{}, len(raw))
+	for k, v := range raw {
+		var val interface{}
+		if err := json.Unmarshal(v, &val); err != nil {
+			return err
+		}
+		switch {
+		case k == CauseKey:
+			val = unmarshalCause(v, val)
+		case k == StatusKey:
+			if f, ok := val.(float64); ok && f == float64(int(f)) {
+				val = int(f)
+			}
+		}
+		m[k] = val
+	}
+	*e = KVError{kv: m}
+	return nil
+}
+
+// unmarshalCause reconstructs the cause encoded as b and decoded as val
+func unmarshalCause(b []byte, val interface{}) interface{} {
+	switch c := val.(type) {
+	case map[string]interface{}:
+		cause := &KVError{}
+		if err := cause.UnmarshalJSON(b); err == nil {
+			return cause
+		}
+		return c
+	case string:
+		return errors.New(c)
+	default:
+		return val
+	}
+}
+
+// Chain returns the errors in err's chain from err to the deepest cause. An
+// error created by Add is returned in place of the error the key/value pairs
+// were added to rather than in addition to it.
+func Chain(err error) []error {
+	var chain []error
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		chain = append(chain, e)
+		for {
+			kve, ok := e.(*KVError)
+			if !ok || kve.orig == nil {
+				break
+			}
+			e = kve.orig
+		}
+	}
+	return chain
+}
+
+// WithStatus returns err with code, usually an HTTP status code, added under
+// StatusKey. It returns nil if err is nil. See Status
+func WithStatus(err error, code int) error {
+	if err == nil {
+		return nil
+	}
+	return Add(err, StatusKey, code)
+}
+
+// Status returns the status code added to err or any error in its chain with
+// WithStatus. The status closest to err wins.
+func Status(err error) (int, bool) {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		kve, ok := e.(*KVError)
+		if !ok {
+			continue
+		}
+		if code, ok := kve.kv[StatusKey].(int); ok {
+			return code, true
+		}
+	}
+	return 0, false
+}
+
+// Code returns the code of err or of any error in its chain, such as that of
+// a sentinel created by NewSentinel. The code closest to err wins.
+func Code(err error) (string, bool) {
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		kve, ok := e.(*KVError)
+		if !ok {
+			continue
+		}
+		if code, ok := kve.kv[CodeKey].(string); ok {
+			return code, true
+		}
+	}
+	return "", false
+}
+
 // AddCtx appends Context to the error
 func AddCtx(err error, ctx Context) error {
 	return Add(err, ctx...)
@@ -121,6 +279,34 @@ func Unwrap(err error) error {
 	return errors.Unwrap(err)
 }
 
+// As finds the first error in err's chain that matches target, and if so,
+// sets target to that error value and returns true. Otherwise, it returns false.
+//
+// As behaves like errors.As but also traverses errors that have multiple causes
+// by implementing Unwrap() []error, regardless of the Go version in use.
+func As(err error, target interface{}) bool {
+	if err == nil {
+		return false
+	}
+	if errors.As(err, target) {
+		return true
+	}
+	// errors.As already walked the single cause chain so only descend
+	// into the errors that have multiple causes
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		multi, ok := e.(interface{ Unwrap() []error })
+		if !ok {
+			continue
+		}
+		for _, cause := range multi.Unwrap() {
+			if As(cause, target) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 // NewContext creates key/value pairs to be used with errors later in
 // the callstack. This provides the ability to create contextual
 // information that will be used with any returned error
@@ -151,12 +337,15 @@ type Context []interface{}
 
 // New creates a new KVError with this context
 func (c Context) New(msg string, keysAndValues ...interface{}) error {
-	return New(msg, append(keysAndValues, c...)...)
+	return newError(1, msg, append(keysAndValues, c...)...)
 }
 
 // Wrap wraps an error with this context
 func (c Context) Wrap(err error, msg string, keysAndValues ...interface{}) error {
-	return Wrap(err, msg, append(keysAndValues, c...)...)
+	if err == nil {
+		return nil
+	}
+	return newError(1, msg, append(append(keysAndValues, c...), CauseKey, err)...)
 }
 
 // Root unwraps the error until it reaches the root error
@@ -3,7 +3,9 @@ package kverrors_test
 import (
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
+	"sync"
 	"testing"
 
 	"github.com/ViaQ/logerr/internal/kv"
@@ -84,6 +86,37 @@ func TestAs(t *testing.T) {
 	require.EqualValues(t, "a", expected.Letter)
 }
 
+func TestAs_ExtractsFromDeepChain(t *testing.T) {
+	err := kverrors.Wrap(&MyError{"a"}, "e1", "depth", 1)
+	err = kverrors.Wrap(err, "e2", "depth", 2)
+	err = kverrors.Wrap(err, "e3", "depth", 3)
+
+	var expected *MyError
+	require.True(t, kverrors.As(err, &expected), "expected %T to be %T", err, expected)
+	require.EqualValues(t, "a", expected.Letter)
+	require.True(t, errors.As(err, &expected))
+}
+
+func TestAs_ExtractsFromMultiCause(t *testing.T) {
+	multi := multiError{
+		io.ErrUnexpectedEOF,
+		kverrors.Wrap(&MyError{"b"}, "e1"),
+	}
+	err := kverrors.Wrap(multi, "e2")
+
+	var expected *MyError
+	require.True(t, kverrors.As(err, &expected), "expected %T to be %T", err, expected)
+	require.EqualValues(t, "b", expected.Letter)
+}
+
+func TestAs_NoMatch(t *testing.T) {
+	err := kverrors.Wrap(multiError{io.ErrUnexpectedEOF}, "e1")
+
+	var expected *MyError
+	require.False(t, kverrors.As(err, &expected))
+	require.False(t, kverrors.As(nil, &expected))
+}
+
 func TestKVError_Add(t *testing.T) {
 	t.Run("KVerror", func(t *testing.T) {
 		err := kverrors.New(t.Name(), "key", "value")
@@ -107,6 +140,48 @@ func TestKVError_Add(t *testing.T) {
 	})
 }
 
+func TestAdd_DoesNotModifyOriginal(t *testing.T) {
+	sentinel := kverrors.New("sentinel", "key", "value")
+
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			err := kverrors.Add(sentinel, "worker", i)
+			assert.EqualValues(t, i, kverrors.KVs(err)["worker"])
+			assert.True(t, errors.Is(err, sentinel))
+		}(i)
+	}
+	wg.Wait()
+
+	expected := map[string]interface{}{
+		kverrors.MessageKey: "sentinel",
+		"key":               "value",
+	}
+	require.EqualValues(t, expected, kverrors.KVs(sentinel))
+}
+
+func TestAdd_KeepsErrorMessageAndCause(t *testing.T) {
+	err := kverrors.Wrap(io.ErrUnexpectedEOF, "e1")
+	added := kverrors.Add(err, "key", "value")
+
+	require.Equal(t, err.Error(), added.Error())
+	require.Equal(t, io.ErrUnexpectedEOF, kverrors.Root(added))
+	require.True(t, errors.Is(kverrors.Add(io.ErrUnexpectedEOF, "key", "value"), io.ErrUnexpectedEOF))
+}
+
+func TestAdd_DoesNotCopyThroughForeignWrappers(t *testing.T) {
+	inner := kverrors.New("inner", "key", "value")
+	err := kverrors.Add(fmt.Errorf("outer: %w", inner), "added", "value")
+
+	require.Equal(t, map[string]interface{}{
+		kverrors.MessageKey: "outer: inner",
+		"added":             "value",
+	}, kverrors.KVs(err))
+	require.True(t, errors.Is(err, inner))
+}
+
 func TestRoot_FindsTheRootError(t *testing.T) {
 	root := io.ErrUnexpectedEOF
 	err := kverrors.Wrap(kverrors.Wrap(kverrors.Wrap(root, "e1"), "e2"), "e3")
@@ -237,3 +312,97 @@ type MyError struct {
 func (e MyError) Error() string {
 	return e.Letter
 }
+
+// multiError is an error with multiple causes
+type multiError []error
+
+func (m multiError) Error() string {
+	return "multiple errors"
+}
+
+func (m multiError) Unwrap() []error {
+	return m
+}
+
+func TestChain(t *testing.T) {
+	root := io.EOF
+	mid := kverrors.Wrap(root, "read failed")
+	added := kverrors.Add(mid, "file", "a.txt")
+	top := fmt.Errorf("load: %w", added)
+
+	require.Equal(t, []error{top, added, root}, kverrors.Chain(top))
+	require.Nil(t, kverrors.Chain(nil))
+}
+
+func TestStatus(t *testing.T) {
+	err := kverrors.WithStatus(kverrors.New("not found", "id", 42), 404)
+	code, ok := kverrors.Status(err)
+	require.True(t, ok)
+	require.Equal(t, 404, code)
+	require.EqualValues(t, 42, kverrors.KVs(err)["id"])
+
+	wrapped := fmt.Errorf("handler: %w", kverrors.Wrap(err, "failed to get item"))
+	code, ok = kverrors.Status(wrapped)
+	require.True(t, ok)
+	require.Equal(t, 404, code)
+
+	code, ok = kverrors.Status(kverrors.WithStatus(wrapped, 500))
+	require.True(t, ok)
+	require.Equal(t, 500, code, "expected the outermost status")
+
+	_, ok = kverrors.Status(errors.New("no status"))
+	require.False(t, ok)
+	require.NoError(t, kverrors.WithStatus(nil, 500))
+}
+
+func TestNewSentinel_MatchesReconstructedErrors(t *testing.T) {
+	errNotFound := kverrors.NewSentinel("not_found", "not found")
+	errConflict := kverrors.NewSentinel("conflict", "conflict")
+
+	err := kverrors.Wrap(kverrors.Add(errNotFound, "id", 7), "lookup failed", "table", "users")
+	require.True(t, errors.Is(err, errNotFound))
+
+	b, err := json.Marshal(err)
+	require.NoError(t, err)
+
+	got := &kverrors.KVError{}
+	require.NoError(t, json.Unmarshal(b, got))
+	require.Equal(t, "lookup failed: not found", got.Error())
+	require.True(t, errors.Is(got, errNotFound))
+	require.False(t, errors.Is(got, errConflict))
+
+	// errors that are not sentinels still only match themselves
+	plain := kverrors.New("not found", kverrors.CodeKey, "not_found")
+	require.False(t, errors.Is(got, plain))
+	require.True(t, errors.Is(plain, errNotFound))
+}
+
+func TestKVError_UnmarshalJSON(t *testing.T) {
+	err := kverrors.Wrap(kverrors.WithStatus(io.EOF, 404), "read failed", "path", "/tmp")
+	b, err := json.Marshal(err)
+	require.NoError(t, err)
+
+	got := &kverrors.KVError{}
+	require.NoError(t, json.Unmarshal(b, got))
+	require.Equal(t, "/tmp", kverrors.KVs(got)["path"])
+
+	code, ok := kverrors.Status(got)
+	require.True(t, ok)
+	require.Equal(t, 404, code)
+	require.Equal(t, "read failed: EOF", got.Error())
+}
+
+func TestCode(t *testing.T) {
+	errNotFound := kverrors.NewSentinel("not_found", "not found")
+
+	code, ok := kverrors.Code(kverrors.Wrap(errNotFound, "lookup failed"))
+	require.True(t, ok)
+	require.Equal(t, "not_found", code)
+
+	code, ok = kverrors.Code(kverrors.Add(errNotFound, kverrors.CodeKey, "gone"))
+	require.True(t, ok)
+	require.Equal(t, "gone", code, "expected the code closest to the error to win")
+
+	_, ok = kverrors.Code(kverrors.New("plain"))
+	require.False(t, ok)
+}
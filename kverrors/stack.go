@@ -0,0 +1,63 @@
+package kverrors
+
+import (
+	"errors"
+	"runtime"
+	"sync/atomic"
+)
+
+// maxStackDepth is the maximum number of frames captured for a stack
+const maxStackDepth = 32
+
+// captureStacks is non-zero when stacks are captured at error creation
+var captureStacks int32
+
+// CaptureStacks enables or disables capturing the call stack when an error is
+// created with New, NewCtx, Wrap or a Context. Capturing stacks is costly so it
+// is disabled by default.
+func CaptureStacks(enabled bool) {
+	var v int32
+	if enabled {
+		v = 1
+	}
+	atomic.StoreInt32(&captureStacks, v)
+}
+
+// capturingStacks reports whether CaptureStacks is enabled
+func capturingStacks() bool {
+	return atomic.LoadInt32(&captureStacks) != 0
+}
+
+// callers returns the program counters of the stack above skip callers
+func callers(skip int) []uintptr {
+	var pcs [maxStackDepth]uintptr
+	// skip runtime.Callers and callers
+	n := runtime.Callers(skip+2, pcs[:])
+	return pcs[:n]
+}
+
+// Stack returns the call stack captured when the deepest *KVError in err's
+// chain that has a stack was created. It returns nil if no stack was
+// captured, see CaptureStacks.
+func Stack(err error) []runtime.Frame {
+	var stack []uintptr
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		if kve, ok := e.(*KVError); ok && len(kve.stack) > 0 {
+			stack = kve.stack
+		}
+	}
+	if stack == nil {
+		return nil
+	}
+
+	frames := runtime.CallersFrames(stack)
+	res := make([]runtime.Frame, 0, len(stack))
+	for {
+		frame, more := frames.Next()
+		res = append(res, frame)
+		if !more {
+			break
+		}
+	}
+	return res
+}
@@ -0,0 +1,46 @@
+package kverrors_test
+
+import (
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/ViaQ/logerr/kverrors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStack_NotCapturedByDefault(t *testing.T) {
+	err := kverrors.New(t.Name())
+	require.Nil(t, kverrors.Stack(err))
+}
+
+func TestStack_CapturedWhenEnabled(t *testing.T) {
+	kverrors.CaptureStacks(true)
+	defer kverrors.CaptureStacks(false)
+
+	for name, err := range map[string]error{
+		"New":  kverrors.New(t.Name()),
+		"Wrap": kverrors.Wrap(io.ErrUnexpectedEOF, t.Name()),
+		"Ctx":  kverrors.NewContext("key", "value").New(t.Name()),
+	} {
+		stack := kverrors.Stack(err)
+		require.NotEmpty(t, stack, name)
+		require.True(t, strings.HasSuffix(stack[0].Function, "TestStack_CapturedWhenEnabled"),
+			"%s: expected first frame to be the caller, got %s", name, stack[0].Function)
+	}
+}
+
+func TestStack_ReturnsDeepestStack(t *testing.T) {
+	kverrors.CaptureStacks(true)
+	root := newDeepError()
+	kverrors.CaptureStacks(false)
+
+	err := kverrors.Wrap(root, "outer")
+	stack := kverrors.Stack(kverrors.Add(err, "key", "value"))
+	require.NotEmpty(t, stack)
+	require.True(t, strings.HasSuffix(stack[0].Function, "newDeepError"), stack[0].Function)
+}
+
+func newDeepError() error {
+	return kverrors.New("deep")
+}
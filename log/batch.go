@@ -0,0 +1,149 @@
+package log
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+)
+
+// batchWriter buffers entries written to it and flushes them to the underlying
+// writer in batches. A batch is flushed every interval or as soon as the
+// buffered bytes reach maxBytes, whichever comes first.
+type batchWriter struct {
+	mtx      sync.Mutex
+	w        io.Writer
+	buf      bytes.Buffer
+	pending  int
+	interval time.Duration
+	maxBytes int
+	stop     chan struct{}
+	done     chan struct{}
+	// closed writes through after Close since nothing would flush the batch
+	closed bool
+}
+
+// newBatchWriter creates a batchWriter that flushes to w
+func newBatchWriter(w io.Writer) *batchWriter {
+	return &batchWriter{w: w}
+}
+
+// Write buffers p and flushes the batch if it has reached maxBytes
+func (b *batchWriter) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+
+	n, _ := b.buf.Write(p)
+	b.pending++
+	if b.maxBytes > 0 && b.buf.Len() >= b.maxBytes {
+		return n, b.flush()
+	}
+	// without any trigger configured or once closed there is nothing that
+	// would ever flush the buffer so write through
+	if b.closed || (b.maxBytes <= 0 && b.interval <= 0) {
+		return n, b.flush()
+	}
+	return n, nil
+}
+
+// Flush writes any buffered entries to the underlying writer
+func (b *batchWriter) Flush() error {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.flush()
+}
+
+// flush writes the batch without locking. To lock see Flush
+func (b *batchWriter) flush() error {
+	if b.buf.Len() == 0 {
+		return nil
+	}
+	_, err := b.w.Write(b.buf.Bytes())
+	b.buf.Reset()
+	b.pending = 0
+	return err
+}
+
+// Pending returns the number of writes, usually entries, in the batch
+func (b *batchWriter) Pending() int {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.pending
+}
+
+// setWriter flushes the current batch and replaces the underlying writer,
+// which is returned
+func (b *batchWriter) setWriter(w io.Writer) io.Writer {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	_ = b.flush()
+	prev := b.w
+	b.w = w
+	return prev
+}
+
+// setMaxBytes sets the number of buffered bytes that triggers a flush
+func (b *batchWriter) setMaxBytes(n int) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.maxBytes = n
+}
+
+// setInterval (re)starts the background flusher to flush every d. A
+// non-positive d stops the background flusher.
+func (b *batchWriter) setInterval(d time.Duration) {
+	b.stopFlusher()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.interval = d
+	if d <= 0 {
+		return
+	}
+
+	b.stop = make(chan struct{})
+	b.done = make(chan struct{})
+	go b.run(d, b.stop, b.done)
+}
+
+// run flushes the batch every d until stop is closed
+func (b *batchWriter) run(d time.Duration, stop, done chan struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(d)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = b.Flush()
+		case <-stop:
+			return
+		}
+	}
+}
+
+// stopFlusher stops the background flusher and waits for it to exit
+func (b *batchWriter) stopFlusher() {
+	b.mtx.Lock()
+	stop, done := b.stop, b.done
+	b.stop, b.done = nil, nil
+	b.mtx.Unlock()
+
+	if stop == nil {
+		return
+	}
+	close(stop)
+	<-done
+}
+
+// Close stops the background flusher and flushes any partial batch. Later
+// writes are written through.
+func (b *batchWriter) Close() error {
+	b.stopFlusher()
+
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	b.closed = true
+	return b.flush()
+}
@@ -0,0 +1,164 @@
+package log_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+// syncBuffer is a bytes.Buffer that is safe to use from multiple goroutines
+type syncBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+func (s *syncBuffer) Write(p []byte) (int, error) {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.buf.Write(p)
+}
+
+func (s *syncBuffer) String() string {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.buf.String()
+}
+
+func (s *syncBuffer) Len() int {
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+	return s.buf.Len()
+}
+
+func TestWithFlushBytes_FlushesWhenThresholdReached(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithFlushBytes(1 << 20),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Info("hello, world")
+	require.Zero(t, buf.Len(), "expected entry to be buffered")
+	require.NoError(t, log.Close())
+
+	flushed := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(flushed),
+		log.WithFlushBytes(1),
+	})
+	log.Info("hello, world")
+	require.Contains(t, flushed.String(), "hello, world")
+}
+
+func TestWithFlushInterval_FlushesPeriodically(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithFlushInterval(10 * time.Millisecond),
+		log.WithFlushBytes(1 << 20),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Info("hello, world")
+
+	require.Eventually(t, func() bool {
+		return strings.Contains(buf.String(), "hello, world")
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestClose_FlushesPartialBatch(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithFlushInterval(time.Hour),
+		log.WithFlushBytes(1 << 20),
+	})
+
+	log.Info("first")
+	log.Info("second")
+	require.Zero(t, buf.Len(), "expected entries to be buffered")
+
+	require.NoError(t, log.Close())
+	require.Contains(t, buf.String(), "first")
+	require.Contains(t, buf.String(), "second")
+}
+
+func TestClose_WritesThroughAfterwards(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithFlushInterval(time.Hour),
+		log.WithFlushBytes(1 << 20),
+	})
+	defer log.MustInit("")
+	require.NoError(t, log.Close())
+
+	log.Info("after close")
+	require.Contains(t, buf.String(), "after close")
+}
+
+func TestInitE_ClosesPreviousBatchedLogger(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithFlushInterval(time.Hour),
+		log.WithFlushBytes(1 << 20),
+	})
+	child := log.WithName("child")
+	log.Info("buffered")
+	require.Zero(t, buf.Len(), "expected entry to be buffered")
+
+	log.MustInit("")
+	require.Contains(t, buf.String(), "buffered")
+
+	child.Info("after init")
+	require.Contains(t, buf.String(), "after init")
+}
+
+func TestWithFlushOnError(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithFlushInterval(time.Hour),
+		log.WithFlushBytes(1 << 20),
+		log.WithFlushOnError(true),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Info("first")
+	require.Zero(t, buf.Len(), "expected entry to be buffered")
+
+	log.WithName("child").Error(errors.New("fail boat"), "second")
+	require.Contains(t, buf.String(), "first")
+	require.Contains(t, buf.String(), "second")
+}
+
+func TestPending(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithFlushInterval(time.Hour),
+		log.WithFlushBytes(1 << 20),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	require.Zero(t, log.Pending())
+	for i := 0; i < 3; i++ {
+		log.Info("hello, world", "i", i)
+	}
+	require.Equal(t, 3, log.Pending())
+	require.Zero(t, buf.Len())
+
+	ll, err := log.Sink()
+	require.NoError(t, err)
+	require.NoError(t, ll.Flush())
+	require.Zero(t, log.Pending())
+	require.Equal(t, 3, strings.Count(buf.String(), "\n"))
+}
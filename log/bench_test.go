@@ -0,0 +1,120 @@
+package log_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ViaQ/logerr/log"
+)
+
+func BenchmarkLogger_Info(b *testing.B) {
+	logger := log.NewLogger("benchmark", ioutil.Discard, 0, log.JSONEncoder{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info("hello, world")
+	}
+}
+
+func BenchmarkLogger_Info_WithFields(b *testing.B) {
+	logger := log.NewLogger("benchmark", ioutil.Discard, 0, log.JSONEncoder{}).WithValues("cluster", "prod", "zone", "eu-1")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info("hello, world", "city", "Athens", "attempt", i, "ok", true)
+	}
+}
+
+func BenchmarkLogger_Error(b *testing.B) {
+	logger := log.NewLogger("benchmark", ioutil.Discard, 0, log.JSONEncoder{})
+	err := errors.New("fail boat")
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Error(err, "hello, world", "attempt", i)
+	}
+}
+
+func BenchmarkLogger_V_Disabled(b *testing.B) {
+	log.SetLogLevel(0)
+	logger := log.NewLogger("benchmark", ioutil.Discard, 0, log.JSONEncoder{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.V(3).Info("hello, world", "attempt", i)
+	}
+}
+
+func BenchmarkJSONEncoder_Encode(b *testing.B) {
+	enc := log.JSONEncoder{}
+	entry := log.Entry{
+		Timestamp: "2021-01-01T00:00:00Z",
+		Level:     "0",
+		Component: "benchmark",
+		Message:   "hello, world",
+		Fields: map[string]interface{}{
+			"city":    "Athens",
+			"attempt": 1,
+		},
+	}
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_ = enc.Encode(ioutil.Discard, entry)
+	}
+}
+
+func BenchmarkLogger_Info_TypedFields(b *testing.B) {
+	logger := log.NewLogger("benchmark", ioutil.Discard, 0, log.JSONEncoder{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info("hello, world", log.String("city", "Athens"), log.Int("attempt", i), log.String("zone", "eu-1"))
+	}
+}
+
+func BenchmarkLogger_Info_InterfaceFields(b *testing.B) {
+	logger := log.NewLogger("benchmark", ioutil.Discard, 0, log.JSONEncoder{})
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		logger.Info("hello, world", "city", "Athens", "attempt", i, "zone", "eu-1")
+	}
+}
+
+// BenchmarkLogger_Info_ConcurrentCounters compares counting entries with a
+// mutex to counting them atomically, like the builtin counters, while logging
+// from many goroutines
+func BenchmarkLogger_Info_ConcurrentCounters(b *testing.B) {
+	var (
+		mtx     sync.Mutex
+		counted uint64
+	)
+	counters := map[string]log.Option{
+		"mutex": log.WithHook(func(log.Entry) {
+			mtx.Lock()
+			counted++
+			mtx.Unlock()
+		}),
+		"atomic": log.WithHook(func(log.Entry) {
+			atomic.AddUint64(&counted, 1)
+		}),
+		"builtin": log.WithSequenceNumbers(true),
+	}
+	for _, name := range []string{"mutex", "atomic", "builtin"} {
+		b.Run(name, func(b *testing.B) {
+			logger := log.NewLogger("benchmark", ioutil.Discard, 0, log.JSONEncoder{})
+			counters[name](logger)
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				for pb.Next() {
+					logger.Info("hello, world")
+				}
+			})
+		})
+	}
+}
@@ -0,0 +1,81 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+// collapser suppresses consecutive repeated entries. See WithCollapseRepeats
+type collapser struct {
+	mtx    sync.Mutex
+	window time.Duration
+
+	// key identifies the entry that started the current run
+	key   string
+	start time.Time
+	// count is the number of entries suppressed in the current run, last
+	// is the last of them and logger the logger it was logged with
+	count  int
+	last   Entry
+	logger *Logger
+	timer  *time.Timer
+}
+
+// collapse reports whether m, logged with l, repeats the current run and is
+// suppressed. Otherwise the run is ended and m starts a new one.
+func (c *collapser) collapse(l *Logger, m Entry) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	key := collapseKey(m)
+	if key == c.key && m.Time.Sub(c.start) < c.window {
+		c.count++
+		c.last, c.logger = m, l
+		if c.timer == nil {
+			c.timer = time.AfterFunc(c.window-m.Time.Sub(c.start), c.flush)
+		}
+		return true
+	}
+
+	c.end()
+	c.key, c.start = key, m.Time
+	return false
+}
+
+// flush ends the current run
+func (c *collapser) flush() {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.end()
+	c.key = ""
+}
+
+// end writes the last suppressed entry of the current run with the number of
+// suppressed entries. c.mtx must be held.
+func (c *collapser) end() {
+	if c.timer != nil {
+		c.timer.Stop()
+		c.timer = nil
+	}
+	if c.count == 0 {
+		return
+	}
+
+	m := c.last
+	fields := make(map[string]interface{}, len(m.Fields)+1)
+	for k, v := range m.Fields {
+		fields[k] = v
+	}
+	fields[RepeatedKey] = c.count
+	m.Fields = fields
+	c.logger.emit(m)
+
+	c.count, c.last, c.logger = 0, Entry{}, nil
+}
+
+// collapseKey returns the key identifying repeats of m
+func collapseKey(m Entry) string {
+	parts := append([]string{m.Level, m.Component, m.Message}, sortedKeys(m.Fields)...)
+	return strings.Join(parts, "\x00")
+}
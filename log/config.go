@@ -0,0 +1,122 @@
+package log
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+// Config returns a snapshot of the configuration of l for debugging. The
+// snapshot only contains strings, numbers, booleans and slices of those, so
+// it can be logged or served as JSON.
+func (l *Logger) Config() map[string]interface{} {
+	// the global lock is always taken before the lock of a logger
+	mtx.RLock()
+	level := logLevel
+	mtx.RUnlock()
+
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	cfg := map[string]interface{}{
+		"component":     l.name,
+		"level":         level,
+		"verbosity":     int(l.verbosity),
+		"encoder":       fmt.Sprintf("%T", l.encoder),
+		"fields":        len(l.context),
+		"error_key":     l.errorKey(),
+		"strict_keys":   l.opts.strictKeys,
+		"fanout":        len(l.opts.fanout),
+		"reserved_keys": reservedKeyNames(l.reservedKeys()),
+	}
+	if enc, ok := l.encoder.(JSONEncoder); ok {
+		cfg["component_key"] = l.componentKey()
+		cfg["stack_trace"] = enc.StackTrace
+		cfg["error_chain"] = enc.ErrorChain
+		cfg["escape_html"] = enc.EscapeHTML
+		cfg["entry_size"] = enc.EntrySize
+		cfg["service_object"] = enc.ServiceObject
+		if len(enc.PromotedKeys) > 0 {
+			cfg["promoted_keys"] = append([]string(nil), enc.PromotedKeys...)
+		}
+		if len(enc.FieldOrder) > 0 {
+			cfg["field_order"] = append([]string(nil), enc.FieldOrder...)
+		}
+	}
+
+	output := l.output
+	if bw, ok := output.(*batchWriter); ok {
+		bw.mtx.Lock()
+		output = bw.w
+		cfg["flush_interval"] = bw.interval.String()
+		cfg["flush_bytes"] = bw.maxBytes
+		bw.mtx.Unlock()
+	}
+	cfg["output"] = describeOutput(output)
+
+	if l.opts.fieldNamespace != "" {
+		cfg["field_namespace"] = l.opts.fieldNamespace
+	}
+	if l.opts.levelNumericKey != "" {
+		cfg["level_numeric_key"] = l.opts.levelNumericKey
+	}
+	if l.opts.localTimestampKey != "" {
+		cfg["local_timestamp_key"] = l.opts.localTimestampKey
+	}
+	if l.opts.maxFields > 0 {
+		cfg["max_fields"] = l.opts.maxFields
+	}
+	if l.opts.maxArrayElements > 0 {
+		cfg["max_array_elements"] = l.opts.maxArrayElements
+	}
+	if l.opts.collapser != nil {
+		cfg["collapse_repeats"] = l.opts.collapser.window.String()
+	}
+	if l.opts.registry != nil {
+		cfg["dynamic_fields"] = l.DynamicFields()
+		cfg["hooks"] = l.Hooks()
+	}
+	cfg["sequence_numbers"] = l.opts.seq != nil
+	cfg["goroutine_id"] = l.opts.goroutineID
+	cfg["flush_on_error"] = l.opts.flushOnError
+	return cfg
+}
+
+// Config returns a snapshot of the configuration of the root logger if it is
+// *log.Logger, otherwise it only contains the level and the type of the root
+// logger. See Logger.Config
+func Config() map[string]interface{} {
+	ll, err := Sink()
+	if err != nil {
+		mtx.RLock()
+		defer mtx.RUnlock()
+		return map[string]interface{}{
+			"level":       logLevel,
+			"logger_type": fmt.Sprintf("%T", root()),
+		}
+	}
+	return ll.Config()
+}
+
+// describeOutput returns a description of w
+func describeOutput(w io.Writer) string {
+	switch w {
+	case os.Stdout:
+		return "stdout"
+	case os.Stderr:
+		return "stderr"
+	}
+	if f, ok := w.(*os.File); ok {
+		return f.Name()
+	}
+	return fmt.Sprintf("%T", w)
+}
+
+// reservedKeyNames returns the keys of keys
+func reservedKeyNames(keys []reservedKey) []string {
+	res := make([]string, 0, len(keys))
+	for _, k := range keys {
+		res = append(res, k.key)
+	}
+	return res
+}
@@ -0,0 +1,47 @@
+package log_test
+
+import (
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfig(t *testing.T) {
+	defer log.SetLogLevel(0)
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(os.Stderr),
+		log.WithLogLevel(2),
+		log.WithFlushInterval(time.Second),
+		log.WithErrorKey("err"),
+		log.WithSequenceNumbers(true),
+		log.WithMaxFields(10),
+	}, "cluster", "prod")
+	defer func() { _ = log.Close() }()
+
+	cfg := log.Config()
+	require.Equal(t, t.Name(), cfg["component"])
+	require.Equal(t, 2, cfg["level"])
+	require.Equal(t, "log.JSONEncoder", cfg["encoder"])
+	require.Equal(t, "stderr", cfg["output"])
+	require.Equal(t, "1s", cfg["flush_interval"])
+	require.Equal(t, 1, cfg["fields"])
+	require.Equal(t, "err", cfg["error_key"])
+	require.Equal(t, true, cfg["sequence_numbers"])
+	require.Equal(t, 10, cfg["max_fields"])
+	require.Contains(t, cfg["reserved_keys"], "err")
+
+	_, err := json.Marshal(cfg)
+	require.NoError(t, err)
+}
+
+func TestConfig_WithUnknownLogger(t *testing.T) {
+	log.UseLogger(nopLogger{})
+
+	cfg := log.Config()
+	require.Equal(t, "log_test.nopLogger", cfg["logger_type"])
+	require.Contains(t, cfg, "level")
+}
@@ -0,0 +1,106 @@
+package log
+
+import (
+	"context"
+	"errors"
+
+	"github.com/go-logr/logr"
+)
+
+// contextKey is the key the logger is stored under in a context.Context
+type contextKey struct{}
+
+// contextLogger is the value stored in a context.Context. The key/value pairs
+// are only merged onto the logger when it is retrieved with FromContext.
+type contextLogger struct {
+	logger        logr.Logger
+	keysAndValues []interface{}
+	// buffer holds the entries of the logger until the end of the request.
+	// See TraceBuffer
+	buffer *traceBuffer
+}
+
+// IntoContext returns a copy of ctx that carries logger. Values previously
+// added with WithValuesContext are discarded, a TraceBuffer is kept.
+func IntoContext(ctx context.Context, logger logr.Logger) context.Context {
+	cl, _ := ctx.Value(contextKey{}).(contextLogger)
+	return context.WithValue(ctx, contextKey{}, contextLogger{logger: logger, buffer: cl.buffer})
+}
+
+// WithValuesContext returns a copy of ctx whose logger has keysAndValues added
+// to the values already carried by ctx, so that nested layers such as
+// middleware can each add their own fields.
+func WithValuesContext(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	cl, _ := ctx.Value(contextKey{}).(contextLogger)
+	cl.keysAndValues = append(append([]interface{}{}, cl.keysAndValues...), keysAndValues...)
+	return context.WithValue(ctx, contextKey{}, cl)
+}
+
+// FromContext returns the logger carried by ctx with all values added with
+// WithValuesContext. If ctx carries no logger, the root logger is used.
+func FromContext(ctx context.Context) logr.Logger {
+	cl, _ := ctx.Value(contextKey{}).(contextLogger)
+	logger := cl.logger
+	if logger == nil {
+		logger = GetLogger()
+	}
+	if ll, ok := logger.(*Logger); ok {
+		if cl.buffer != nil {
+			ll = ll.clone()
+			ll.opts.buffer = cl.buffer
+		}
+		logger = ll.forContext(ctx)
+	}
+	if len(cl.keysAndValues) == 0 {
+		return logger
+	}
+	return logger.WithValues(cl.keysAndValues...)
+}
+
+// forContext returns l with the settings that depend on ctx applied
+func (l *Logger) forContext(ctx context.Context) logr.Logger {
+	if !l.opts.runtimeTrace && len(l.opts.correlation) == 0 {
+		return l
+	}
+	ll := l.clone()
+	if l.opts.runtimeTrace {
+		ll.opts.traceCtx = ctx
+	}
+	if id, ok := l.correlationID(ctx); ok {
+		ll.context = combine(ll.context, CorrelationIDKey, id)
+	}
+	return ll
+}
+
+// Keys of the fields added by ErrorContext
+const (
+	ContextReasonKey = "_context_reason"
+	ContextCauseKey  = "_context_cause"
+)
+
+// Values of ContextReasonKey
+const (
+	ReasonCanceled         = "canceled"
+	ReasonDeadlineExceeded = "deadline_exceeded"
+)
+
+// ErrorContext logs err with the logger of ctx, see FromContext. If err is
+// context.Canceled or context.DeadlineExceeded the reason is added under
+// ContextReasonKey. If ctx is done and was canceled with a cause, which
+// requires Go 1.20, the cause is added under ContextCauseKey.
+func ErrorContext(ctx context.Context, err error, msg string, keysAndValues ...interface{}) {
+	// copy so that appending never writes into the array of the caller
+	keysAndValues = append(make([]interface{}, 0, len(keysAndValues)+4), keysAndValues...)
+	switch {
+	case errors.Is(err, context.Canceled):
+		keysAndValues = append(keysAndValues, ContextReasonKey, ReasonCanceled)
+	case errors.Is(err, context.DeadlineExceeded):
+		keysAndValues = append(keysAndValues, ContextReasonKey, ReasonDeadlineExceeded)
+	}
+	if ctx.Err() != nil {
+		if cause := contextCause(ctx); cause != nil && cause != ctx.Err() {
+			keysAndValues = append(keysAndValues, ContextCauseKey, cause.Error())
+		}
+	}
+	FromContext(ctx).Error(err, msg, keysAndValues...)
+}
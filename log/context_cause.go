@@ -0,0 +1,13 @@
+//go:build go1.20
+// +build go1.20
+
+package log
+
+import (
+	"context"
+)
+
+// contextCause returns the cause ctx was canceled with
+func contextCause(ctx context.Context) error {
+	return context.Cause(ctx)
+}
@@ -0,0 +1,13 @@
+//go:build !go1.20
+// +build !go1.20
+
+package log
+
+import (
+	"context"
+)
+
+// contextCause returns nil because causes require Go 1.20
+func contextCause(context.Context) error {
+	return nil
+}
@@ -0,0 +1,26 @@
+//go:build go1.20
+// +build go1.20
+
+package log_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestErrorContext_Cause(t *testing.T) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errors.New("client went away"))
+
+	obs, logger := NewObservedLogger()
+	log.ErrorContext(log.IntoContext(ctx, logger), ctx.Err(), "hello")
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 1)
+	require.Equal(t, log.ReasonCanceled, logs[0].Context[log.ContextReasonKey])
+	require.Equal(t, "client went away", logs[0].Context[log.ContextCauseKey])
+}
@@ -0,0 +1,151 @@
+package log_test
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/ViaQ/logerr/kverrors"
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFromContext_ReturnsLoggerFromIntoContext(t *testing.T) {
+	obs, logger := NewObservedLogger()
+	ctx := log.IntoContext(context.Background(), logger.WithValues("zone", "eu-1"))
+
+	log.FromContext(ctx).Info("hello")
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 1)
+	require.Equal(t, "eu-1", logs[0].Context["zone"])
+}
+
+func TestFromContext_DefaultsToRootLogger(t *testing.T) {
+	obs, logger := NewObservedLogger()
+	log.UseLogger(logger)
+
+	ctx := log.WithValuesContext(context.Background(), "request", "abc")
+	log.FromContext(ctx).Info("hello")
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 1)
+	require.Equal(t, "abc", logs[0].Context["request"])
+}
+
+func TestWithValuesContext_MergesLayers(t *testing.T) {
+	obs, logger := NewObservedLogger()
+	ctx := log.IntoContext(context.Background(), logger)
+
+	outer := log.WithValuesContext(ctx, "request", "abc")
+	inner := log.WithValuesContext(outer, "user", "alice")
+	log.FromContext(inner).Info("hello")
+	log.FromContext(outer).Info("hello")
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 2)
+	require.Equal(t, "abc", logs[0].Context["request"])
+	require.Equal(t, "alice", logs[0].Context["user"])
+	require.Equal(t, "abc", logs[1].Context["request"])
+	require.NotContains(t, logs[1].Context, "user")
+}
+
+func TestErrorContext(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	expired, cancel := context.WithTimeout(context.Background(), 0)
+	defer cancel()
+
+	tests := []struct {
+		desc   string
+		ctx    context.Context
+		err    error
+		reason interface{}
+	}{
+		{"canceled", canceled, canceled.Err(), log.ReasonCanceled},
+		{"deadline exceeded", expired, expired.Err(), log.ReasonDeadlineExceeded},
+		{"wrapped", canceled, kverrors.Wrap(canceled.Err(), "request failed"), log.ReasonCanceled},
+		{"other error", canceled, errors.New("fail boat"), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			obs, logger := NewObservedLogger()
+			log.ErrorContext(log.IntoContext(tt.ctx, logger), tt.err, "hello")
+
+			logs := obs.TakeAll()
+			require.Len(t, logs, 1)
+			require.Equal(t, tt.reason, logs[0].Context[log.ContextReasonKey])
+			require.NotContains(t, logs[0].Context, log.ContextCauseKey)
+		})
+	}
+}
+
+func TestErrorContext_DoesNotModifyKeysAndValues(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	obs, logger := NewObservedLogger()
+	backing := []interface{}{"user", "alice", "spare", "spare"}
+	kvs := backing[:2]
+	log.ErrorContext(log.IntoContext(canceled, logger), canceled.Err(), "hello", kvs...)
+
+	require.Len(t, obs.TakeAll(), 1)
+	require.Equal(t, []interface{}{"user", "alice", "spare", "spare"}, backing)
+}
+
+func TestTraceBuffer_DiscardsOnSuccess(t *testing.T) {
+	obs, logger := NewObservedLogger()
+	ctx, done := log.TraceBuffer(log.IntoContext(context.Background(), logger))
+
+	log.FromContext(ctx).Info("step 1")
+	log.FromContext(ctx).V(0).Info("step 2")
+	require.Empty(t, obs.TakeAll(), "expected entries to be held")
+
+	done(false)
+	require.Empty(t, obs.TakeAll())
+
+	log.FromContext(ctx).Info("after")
+	require.Len(t, obs.TakeAll(), 1, "expected entries after done to be written")
+}
+
+func TestTraceBuffer_EmitsOnFlush(t *testing.T) {
+	obs, logger := NewObservedLogger()
+	ctx, done := log.TraceBuffer(log.IntoContext(context.Background(), logger))
+	ctx = log.WithValuesContext(ctx, "request", "r-1")
+
+	log.FromContext(ctx).Info("step 1")
+	log.FromContext(ctx).Error(errors.New("fail boat"), "step 2")
+	logger.Info("outside the request")
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 1)
+	require.Equal(t, "outside the request", logs[0].Message)
+
+	done(true)
+	done(true)
+	logs = obs.TakeAll()
+	require.Len(t, logs, 2)
+	require.Equal(t, "step 1", logs[0].Message)
+	require.Equal(t, "r-1", logs[0].Context["request"])
+	require.Equal(t, "step 2", logs[1].Message)
+	require.EqualError(t, logs[1].Error, "fail boat")
+}
+
+func TestTraceBuffer_DropsOldest(t *testing.T) {
+	obs, logger := NewObservedLogger()
+	ctx, done := log.TraceBuffer(log.IntoContext(context.Background(), logger))
+
+	const dropped = 5
+	for i := 0; i < log.TraceBufferSize+dropped; i++ {
+		log.FromContext(ctx).Info(strconv.Itoa(i))
+	}
+	require.Empty(t, obs.TakeAll(), "expected entries to be held")
+
+	done(true)
+	logs := obs.TakeAll()
+	require.Len(t, logs, log.TraceBufferSize)
+	for i, l := range logs {
+		require.Equal(t, strconv.Itoa(i+dropped), l.Message)
+	}
+}
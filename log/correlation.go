@@ -0,0 +1,65 @@
+package log
+
+import (
+	"context"
+	"net/http"
+)
+
+// CorrelationIDKey holds the correlation id found by the correlation
+// extractors. See WithCorrelation
+const CorrelationIDKey = "correlation_id"
+
+// CorrelationExtractor finds the id correlating the entries of a request in
+// a context. Implement it for transports that carry the id in their own way.
+type CorrelationExtractor interface {
+	CorrelationID(ctx context.Context) (id string, ok bool)
+}
+
+// CorrelationExtractorFunc adapts a function to CorrelationExtractor
+type CorrelationExtractorFunc func(ctx context.Context) (string, bool)
+
+// CorrelationID implements CorrelationExtractor
+func (f CorrelationExtractorFunc) CorrelationID(ctx context.Context) (string, bool) {
+	return f(ctx)
+}
+
+// WithCorrelation adds the correlation id found by extractor under
+// CorrelationIDKey to loggers returned by FromContext. Extractors are tried in
+// the order they were added and the first id found is used.
+func WithCorrelation(extractor CorrelationExtractor) Option {
+	return func(l *Logger) {
+		l.opts.correlation = append(append([]CorrelationExtractor{}, l.opts.correlation...), extractor)
+	}
+}
+
+// correlationID returns the first correlation id found in ctx
+func (l *Logger) correlationID(ctx context.Context) (string, bool) {
+	for _, e := range l.opts.correlation {
+		if id, ok := e.CorrelationID(ctx); ok && id != "" {
+			return id, true
+		}
+	}
+	return "", false
+}
+
+// httpRequestKey is the key the request is stored under by HTTPRequestContext
+type httpRequestKey struct{}
+
+// HTTPRequestContext returns the context of r carrying r so that
+// HTTPCorrelation can find its headers
+func HTTPRequestContext(r *http.Request) context.Context {
+	return context.WithValue(r.Context(), httpRequestKey{}, r)
+}
+
+// HTTPCorrelation returns an extractor for the correlation id in the header
+// of the request carried by a context returned by HTTPRequestContext
+func HTTPCorrelation(header string) CorrelationExtractor {
+	return CorrelationExtractorFunc(func(ctx context.Context) (string, bool) {
+		r, ok := ctx.Value(httpRequestKey{}).(*http.Request)
+		if !ok {
+			return "", false
+		}
+		id := r.Header.Get(header)
+		return id, id != ""
+	})
+}
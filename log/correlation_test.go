@@ -0,0 +1,44 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+type correlationKey struct{}
+
+func TestWithCorrelation(t *testing.T) {
+	fake := log.CorrelationExtractorFunc(func(ctx context.Context) (string, bool) {
+		id, ok := ctx.Value(correlationKey{}).(string)
+		return id, ok
+	})
+
+	buf := &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithCorrelation(fake),
+		log.WithCorrelation(log.HTTPCorrelation("X-Request-Id")),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	ctx := context.WithValue(context.Background(), correlationKey{}, "abc")
+	log.FromContext(log.WithValuesContext(ctx, "user", "alice")).Info("hello")
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, "abc", entry[log.CorrelationIDKey])
+	require.Equal(t, "alice", entry["user"])
+
+	buf.Reset()
+	r := httptest.NewRequest("GET", "/", nil)
+	r.Header.Set("X-Request-Id", "def")
+	log.FromContext(log.HTTPRequestContext(r)).Info("hello")
+	require.Equal(t, "def", decodeEntry(t, buf.Bytes())[log.CorrelationIDKey])
+
+	buf.Reset()
+	log.FromContext(context.Background()).Info("hello")
+	require.NotContains(t, decodeEntry(t, buf.Bytes()), log.CorrelationIDKey)
+}
@@ -0,0 +1,120 @@
+package log
+
+import (
+	"sort"
+	"sync"
+)
+
+// registry holds the dynamic fields and hooks of a logger. It is shared by
+// all derived loggers and may be changed while they log.
+type registry struct {
+	mtx     sync.RWMutex
+	dynamic map[string]func() interface{}
+	hooks   []func(Entry)
+}
+
+// registry returns the registry of l, creating it if necessary. It must only
+// be called by options
+func (l *Logger) registry() *registry {
+	if l.opts.registry == nil {
+		l.opts.registry = &registry{dynamic: map[string]func() interface{}{}}
+	}
+	return l.opts.registry
+}
+
+// WithDynamicField adds key to every entry with the value returned by fn at
+// the time the entry is logged, unless the entry sets key itself. fn must be
+// safe to call from multiple goroutines. See Logger.RemoveDynamicField
+func WithDynamicField(key string, fn func() interface{}) Option {
+	return func(l *Logger) {
+		r := l.registry()
+		r.mtx.Lock()
+		defer r.mtx.Unlock()
+		r.dynamic[key] = fn
+	}
+}
+
+// WithHook calls fn with every entry that is logged before it is written. fn
+// must not modify the entry and must be safe to call from multiple
+// goroutines. See Logger.ClearHooks
+func WithHook(fn func(Entry)) Option {
+	return func(l *Logger) {
+		r := l.registry()
+		r.mtx.Lock()
+		defer r.mtx.Unlock()
+		r.hooks = append(r.hooks, fn)
+	}
+}
+
+// DynamicFields returns the sorted keys of the dynamic fields of l
+func (l *Logger) DynamicFields() []string {
+	r := l.opts.registry
+	if r == nil {
+		return nil
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	keys := make([]string, 0, len(r.dynamic))
+	for k := range r.dynamic {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// RemoveDynamicField removes the dynamic field key from l and all loggers
+// derived from the same root
+func (l *Logger) RemoveDynamicField(key string) {
+	r := l.opts.registry
+	if r == nil {
+		return
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	delete(r.dynamic, key)
+}
+
+// Hooks returns the number of hooks of l
+func (l *Logger) Hooks() int {
+	r := l.opts.registry
+	if r == nil {
+		return 0
+	}
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	return len(r.hooks)
+}
+
+// ClearHooks removes all hooks from l and all loggers derived from the same
+// root
+func (l *Logger) ClearHooks() {
+	r := l.opts.registry
+	if r == nil {
+		return
+	}
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.hooks = nil
+}
+
+// withDynamicFields adds the values of the dynamic fields that context does
+// not set
+func (r *registry) withDynamicFields(context map[string]interface{}) {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+	for k, fn := range r.dynamic {
+		if _, ok := context[k]; !ok {
+			context[k] = fn()
+		}
+	}
+}
+
+// runHooks calls all hooks with m
+func (r *registry) runHooks(m Entry) {
+	r.mtx.RLock()
+	hooks := r.hooks
+	r.mtx.RUnlock()
+	for _, fn := range hooks {
+		fn(m)
+	}
+}
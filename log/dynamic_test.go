@@ -0,0 +1,95 @@
+package log_test
+
+import (
+	"bytes"
+	"sync"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDynamicFields(t *testing.T) {
+	var requests int64
+	buf := &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithDynamicField("requests", func() interface{} { return atomic.LoadInt64(&requests) }),
+		log.WithDynamicField("build", func() interface{} { return "abc" }),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	ll, err := log.Sink()
+	require.NoError(t, err)
+	require.Equal(t, []string{"build", "requests"}, ll.DynamicFields())
+
+	atomic.StoreInt64(&requests, 3)
+	log.Info("hello", "build", "override")
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, float64(3), entry["requests"])
+	require.Equal(t, "override", entry["build"])
+
+	ll.RemoveDynamicField("requests")
+	require.Equal(t, []string{"build"}, ll.DynamicFields())
+
+	buf.Reset()
+	log.WithName("child").Info("hello")
+	entry = decodeEntry(t, buf.Bytes())
+	require.NotContains(t, entry, "requests")
+	require.Equal(t, "abc", entry["build"])
+}
+
+func TestHooks(t *testing.T) {
+	var (
+		mtx      sync.Mutex
+		messages []string
+	)
+	hook := func(e log.Entry) {
+		mtx.Lock()
+		defer mtx.Unlock()
+		messages = append(messages, e.Message)
+	}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(&bytes.Buffer{}),
+		log.WithHook(hook),
+		log.WithHook(hook),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	ll, err := log.Sink()
+	require.NoError(t, err)
+	require.Equal(t, 2, ll.Hooks())
+
+	log.Info("first")
+	ll.ClearHooks()
+	require.Zero(t, ll.Hooks())
+	log.Info("second")
+
+	require.Equal(t, []string{"first", "first"}, messages)
+}
+
+func TestDynamicFields_ConcurrentLogging(t *testing.T) {
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(&syncBuffer{}),
+		log.WithDynamicField("a", func() interface{} { return 1 }),
+		log.WithHook(func(log.Entry) {}),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+	ll, err := log.Sink()
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				log.Info("hello")
+			}
+		}()
+	}
+	ll.RemoveDynamicField("a")
+	ll.ClearHooks()
+	wg.Wait()
+}
@@ -1,19 +1,439 @@
 package log
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
+	"runtime"
+	"sort"
+	"strconv"
+
+	"github.com/ViaQ/logerr/kverrors"
 )
 
 // JSONEncoder encodes messages as JSON
-type JSONEncoder struct{}
+//
+// JSONEncoder only holds configuration and keeps no state between calls, so
+// a single value can be shared by any number of loggers and goroutines. Each
+// entry is written to the output with a single Write call so that entries
+// logged concurrently to a writer that is safe for concurrent use, like
+// os.Stdout, don't interleave.
+type JSONEncoder struct {
+	// StackTrace adds the stack captured by kverrors to entries with a
+	// logged error. See kverrors.CaptureStacks
+	StackTrace bool
+	// ComponentKey is the key of the component instead of ComponentKey
+	ComponentKey string
+	// ErrorChain lists the errors in the chain of the logged error, from the
+	// logged error to the deepest cause, under ErrorChainKey. Each error is
+	// rendered with its message and key/value pairs, except the cause
+	ErrorChain bool
+	// EscapeHTML escapes <, > and & in strings. It is off by default to keep
+	// URLs readable
+	EscapeHTML bool
+	// PromotedKeys are written first, in the given order, if the entry has
+	// them. All other fields follow, builtin fields first and the rest
+	// sorted by key. Only top level keys can be promoted, see
+	// WithFieldNamespace
+	PromotedKeys []string
+	// FieldOrder fixes the order of all fields: the fields with these keys,
+	// builtin fields included, are written first in the given order and all
+	// other fields follow sorted by key. It takes precedence over
+	// PromotedKeys, which is ignored if FieldOrder is set
+	FieldOrder []string
+	// EntrySize adds the exact size of the encoded entry in bytes, including
+	// the field itself and the trailing newline, as the last field under
+	// EntrySizeKey
+	EntrySize bool
+	// ServiceObject writes the component as the name of an object under
+	// ServiceKey together with the VersionKey and CommitKey fields, like the
+	// service attributes of an OpenTelemetry resource, e.g.
+	// {"_service":{"name":"app","version":"1.2.0"}}. ComponentKey is ignored
+	ServiceObject bool
+}
 
 // Encode encodes the message as JSON to w
 func (j JSONEncoder) Encode(w io.Writer, entry interface{}) error {
-	return json.NewEncoder(w).Encode(entry)
+	var (
+		line Line
+		ok   = true
+	)
+	switch e := entry.(type) {
+	case Entry:
+		line = j.prepare(e.Line(), e.Stack)
+		if j.ErrorChain && e.Error != nil {
+			line.Context[ErrorChainKey] = errorChain(e.Error)
+		}
+		if len(e.Labels) > 0 {
+			line.Context[LabelsKey] = e.Labels
+		}
+	case Line:
+		var stack []runtime.Frame
+		if j.StackTrace {
+			stack = findStack(e.Context)
+		}
+		line = j.prepare(e, stack)
+	default:
+		ok = false
+	}
+	if ok {
+		if j.ServiceObject {
+			line = serviceObject(line)
+		}
+		if j.EntrySize {
+			delete(line.Context, EntrySizeKey)
+		}
+		b, err := marshalLine(line, j.keys(), j.EscapeHTML)
+		if err != nil {
+			return err
+		}
+		if j.EntrySize {
+			b = appendEntrySize(b)
+		}
+		entry = json.RawMessage(b)
+	}
+	enc := json.NewEncoder(w)
+	enc.SetEscapeHTML(j.EscapeHTML)
+	return enc.Encode(entry)
+}
+
+// appendEntrySize adds EntrySizeKey with the size of the resulting line,
+// including the trailing newline, as the last field of the JSON object b
+func appendEntrySize(b []byte) []byte {
+	field := `"` + EntrySizeKey + `":`
+	if len(b) > 2 {
+		field = "," + field
+	}
+	// the size depends on its own number of digits, so grow it until it
+	// accounts for them
+	size := len(b) + len(field) + 1
+	for len(b)+len(field)+len(strconv.Itoa(size))+1 != size {
+		size = len(b) + len(field) + len(strconv.Itoa(size)) + 1
+	}
+
+	res := make([]byte, 0, size)
+	res = append(res, b[:len(b)-1]...)
+	res = append(res, field...)
+	res = strconv.AppendInt(res, int64(size), 10)
+	return append(res, '}')
+}
+
+// serviceObject returns line with its component and service attributes moved
+// into the object under ServiceKey. line must have been prepared so that its
+// context can be modified
+func serviceObject(line Line) Line {
+	service := map[string]interface{}{ServiceNameKey: line.Component}
+	for _, k := range []string{VersionKey, CommitKey} {
+		if v, ok := line.Context[k]; ok {
+			service[k] = v
+			delete(line.Context, k)
+		}
+	}
+	line.Context[ServiceKey] = service
+	return line
+}
+
+// errorChain returns the errors in the chain of err as objects with their
+// message and key/value pairs
+func errorChain(err error) []map[string]interface{} {
+	chain := kverrors.Chain(err)
+	res := make([]map[string]interface{}, 0, len(chain))
+	for _, e := range chain {
+		kve, ok := e.(*kverrors.KVError)
+		if !ok {
+			res = append(res, map[string]interface{}{kverrors.MessageKey: e.Error()})
+			continue
+		}
+		m := make(map[string]interface{}, len(kverrors.KVs(kve)))
+		for k, v := range kverrors.KVs(kve) {
+			if k != kverrors.CauseKey {
+				m[k] = v
+			}
+		}
+		res = append(res, m)
+	}
+	return res
+}
+
+// keys returns the keys of the builtin fields of Line
+func (j JSONEncoder) keys() lineKeys {
+	return lineKeys{
+		component: j.ComponentKey,
+		promoted:  j.PromotedKeys,
+		order:     j.FieldOrder,
+		service:   j.ServiceObject,
+	}
 }
 
-// Encoder encodes messages
+// lineKeys are the keys the builtin fields of Line are encoded as. Empty keys
+// use the default key
+type lineKeys struct {
+	component string
+	// promoted are the keys of the context written before all other fields
+	promoted []string
+	// order are the keys of the fields written first, replacing the usual
+	// order of the other fields by the sorted order
+	order []string
+	// service leaves out the component, which is written in the service
+	// object instead
+	service bool
+}
+
+// or returns key or, if it is empty, def
+func or(key, def string) string {
+	if key == "" {
+		return def
+	}
+	return key
+}
+
+// marshalLine encodes line as a JSON object with the promoted keys of the
+// context first, followed by the builtin fields and the rest of the flattened
+// context sorted by key. If keys has a field order, the fields it lists come
+// first and all others follow sorted by key instead. The file and line are only included at
+// verbosity 2 and above and the message only if it is not empty. <, > and &
+// are only escaped if escapeHTML is set.
+func marshalLine(line Line, keys lineKeys, escapeHTML bool) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	buf.WriteByte('{')
+
+	write := func(key string, value interface{}) error {
+		var (
+			v   []byte
+			err error
+		)
+		if f, ok := value.(Field); ok {
+			v, err = f.appendJSON(nil, escapeHTML)
+		} else {
+			v, err = marshalJSON(value, escapeHTML)
+		}
+		if err != nil {
+			return err
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(appendJSONString(nil, key, escapeHTML))
+		buf.WriteByte(':')
+		buf.Write(v)
+		return nil
+	}
+
+	builtins := builtinFields(line, keys)
+	if len(keys.order) > 0 {
+		fields := make(map[string]interface{}, len(builtins)+len(line.Context))
+		for k, v := range line.Context {
+			fields[k] = v
+		}
+		for _, f := range builtins {
+			fields[f.key] = f.value
+		}
+		for _, k := range keys.order {
+			v, ok := fields[k]
+			if !ok {
+				continue
+			}
+			delete(fields, k)
+			if err := write(k, v); err != nil {
+				return nil, err
+			}
+		}
+		for _, k := range sortedKeys(fields) {
+			if err := write(k, fields[k]); err != nil {
+				return nil, err
+			}
+		}
+		buf.WriteByte('}')
+		return buf.Bytes(), nil
+	}
+
+	promoted := make(map[string]bool, len(keys.promoted))
+	for _, k := range keys.promoted {
+		v, ok := line.Context[k]
+		if !ok || promoted[k] {
+			continue
+		}
+		promoted[k] = true
+		if err := write(k, v); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range builtins {
+		if err := write(f.key, f.value); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, k := range sortedKeys(line.Context) {
+		if promoted[k] {
+			continue
+		}
+		if err := write(k, line.Context[k]); err != nil {
+			return nil, err
+		}
+	}
+
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
+
+// builtinField is a builtin field of Line and the key it is encoded as
+type builtinField struct {
+	key   string
+	value string
+}
+
+// builtinFields returns the builtin fields of line that are encoded, in their
+// usual order. The file and line are only included at verbosity 2 and above
+// and the message only if it is not empty.
+func builtinFields(line Line, keys lineKeys) []builtinField {
+	fields := []builtinField{
+		{TimeStampKey, line.Timestamp},
+		{FileLineKey, line.FileLine},
+		{LevelKey, line.Verbosity},
+		{or(keys.component, ComponentKey), line.Component},
+		{MessageKey, line.Message},
+	}
+	verbosity, err := strconv.Atoi(line.Verbosity)
+	dev := err == nil && verbosity > 1
+
+	res := fields[:0]
+	for _, f := range fields {
+		if f.key == FileLineKey && !dev {
+			continue
+		}
+		if f.key == or(keys.component, ComponentKey) && keys.service {
+			continue
+		}
+		// entries that only carry fields have no message
+		if f.key == MessageKey && f.value == "" {
+			continue
+		}
+		res = append(res, f)
+	}
+	return res
+}
+
+// marshalJSON is json.Marshal that only escapes <, > and & if escapeHTML is
+// set
+func marshalJSON(v interface{}, escapeHTML bool) ([]byte, error) {
+	if escapeHTML {
+		return json.Marshal(v)
+	}
+	buf := bytes.NewBuffer(nil)
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimSuffix(buf.Bytes(), []byte{'\n'}), nil
+}
+
+// prepare returns a copy of line with its context adjusted for encoding:
+// json.RawMessage values that are not valid JSON are replaced by strings and
+// listed under InvalidJSONKey so that they cannot corrupt the line, and stack
+// is added if StackTrace is enabled.
+func (j JSONEncoder) prepare(line Line, stack []runtime.Frame) Line {
+	context, invalid := validateRaw("", line.Context)
+	if len(invalid) > 0 {
+		sort.Strings(invalid)
+		context[InvalidJSONKey] = invalid
+	}
+
+	if j.StackTrace && len(stack) > 0 {
+		frames := make([]string, 0, len(stack))
+		for _, f := range stack {
+			frames = append(frames, fmt.Sprintf("%s %s:%d", f.Function, f.File, f.Line))
+		}
+		context[StackTraceKey] = frames
+	}
+
+	line.Context = context
+	return line
+}
+
+// validateRaw returns a copy of m in which json.RawMessage values, including
+// those of nested maps, that are not valid JSON are replaced by strings. The
+// keys of the replaced values are returned joined by '.' and prefixed with
+// prefix
+func validateRaw(prefix string, m map[string]interface{}) (map[string]interface{}, []string) {
+	res := make(map[string]interface{}, len(m)+1)
+	var invalid []string
+	for k, v := range m {
+		switch val := v.(type) {
+		case json.RawMessage:
+			if !json.Valid(val) {
+				v = string(val)
+				invalid = append(invalid, prefix+k)
+			}
+		case map[string]interface{}:
+			var nested []string
+			v, nested = validateRaw(prefix+k+".", val)
+			invalid = append(invalid, nested...)
+		}
+		res[k] = v
+	}
+	return res, invalid
+}
+
+// findStack returns the stack captured by kverrors of the error in context,
+// if it has one. The error is looked up by value so that it is found
+// regardless of the key it is logged under. If there are multiple errors with
+// stacks the one with the lowest key is used.
+func findStack(context map[string]interface{}) []runtime.Frame {
+	var (
+		stack []runtime.Frame
+		key   string
+	)
+	for k, v := range context {
+		err, ok := v.(error)
+		if !ok || (stack != nil && k > key) {
+			continue
+		}
+		if s := kverrors.Stack(err); len(s) > 0 {
+			stack, key = s, k
+		}
+	}
+	return stack
+}
+
+// Encoder encodes messages. Implement Encoder to use a custom format with
+// NewLogger.
+//
+// Encode is called once per entry with an Entry as entry and must write the
+// whole entry, including any line terminator, to w. It must be safe to call
+// Encode from multiple goroutines at the same time. If Encode returns an error
+// the Logger writes a description of the failure to w instead. The same entry
+// may be passed to several encoders, see WithFanout, so Encode must not
+// modify it.
+//
+// logtest.EncoderConformance verifies an Encoder meets these requirements.
 type Encoder interface {
 	Encode(w io.Writer, entry interface{}) error
 }
+
+// EncodeEntry encodes entry with enc and returns the result. A panic of enc is
+// recovered and returned as an error, so EncodeEntry never panics. If the
+// error is nil the result of the JSONEncoder is a single valid JSON object
+// followed by a newline.
+func EncodeEntry(enc Encoder, entry Entry) (b []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			b, err = nil, kverrors.New("encoder panicked",
+				"encoder", fmt.Sprintf("%T", enc),
+				"panic", fmt.Sprint(r),
+			)
+		}
+	}()
+
+	if enc == nil {
+		return nil, kverrors.New("encoder must not be nil")
+	}
+	buf := bytes.NewBuffer(nil)
+	if err := enc.Encode(buf, entry); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,66 @@
+//go:build go1.18
+// +build go1.18
+
+package log_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ViaQ/logerr/log"
+)
+
+func FuzzEncode(f *testing.F) {
+	f.Add("hello, world", "key", "value", "component", 0)
+	f.Add("", "", "", "", 3)
+	f.Add("line\nbreak\r\t\x00", "_ts", `{"nested":true}`, "a/b", -1)
+	f.Add("\xff\xfe invalid utf-8", "\xffkey", "<html>&</html>", " ", 2)
+
+	f.Fuzz(func(t *testing.T, msg, key, value, component string, verbosity int) {
+		entry := log.Entry{
+			Time:      time.Unix(0, 0).UTC(),
+			Timestamp: "1970-01-01T00:00:00Z",
+			Level:     value,
+			Verbosity: log.Verbosity(verbosity),
+			Component: component,
+			Message:   msg,
+			Caller:    key,
+			Fields: map[string]interface{}{
+				key:       value,
+				value:     json.RawMessage(value),
+				"field":   log.String(key, value),
+				"nested":  map[string]interface{}{key: json.RawMessage(msg)},
+				"numbers": []int{verbosity},
+			},
+		}
+		if verbosity < 0 {
+			entry.Error = errors.New(msg)
+			entry.Fields[log.ErrorKey] = entry.Error
+		}
+
+		for _, enc := range []log.Encoder{
+			log.JSONEncoder{},
+			log.JSONEncoder{EscapeHTML: true, ErrorChain: true, PromotedKeys: []string{key}},
+			log.LogfmtEncoder{},
+			log.ConsoleEncoder{},
+			log.JournaldEncoder{},
+		} {
+			b, err := log.EncodeEntry(enc, entry)
+			if err != nil {
+				t.Fatalf("%T failed to encode entry: %s", enc, err)
+			}
+			if _, ok := enc.(log.JSONEncoder); !ok {
+				continue
+			}
+			if !bytes.HasSuffix(b, []byte{'\n'}) || bytes.Count(b, []byte{'\n'}) != 1 {
+				t.Fatalf("expected a single line, got %q", b)
+			}
+			if !json.Valid(b) {
+				t.Fatalf("invalid JSON: %q", b)
+			}
+		}
+	})
+}
@@ -0,0 +1,271 @@
+package log_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ViaQ/logerr/kverrors"
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONEncoder_StackTrace(t *testing.T) {
+	kverrors.CaptureStacks(true)
+	err := kverrors.New("an error")
+	kverrors.CaptureStacks(false)
+
+	for _, enabled := range []bool{true, false} {
+		buf := bytes.NewBuffer(nil)
+		logger := log.NewLogger("", buf, 0, log.JSONEncoder{StackTrace: enabled})
+		logger.Error(err, "hello, world")
+
+		m := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+
+		stack, ok := m[log.StackTraceKey]
+		require.Equal(t, enabled, ok, "StackTrace: %t", enabled)
+		if enabled {
+			require.NotEmpty(t, stack)
+			require.Contains(t, stack.([]interface{})[0], "TestJSONEncoder_StackTrace")
+		}
+	}
+}
+
+func TestJSONEncoder_StackTrace_NoStackCaptured(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{StackTrace: true})
+	logger.Error(kverrors.New("an error"), "hello, world")
+
+	require.NotContains(t, buf.String(), log.StackTraceKey)
+}
+
+func TestJSONEncoder_RawMessage(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	logger.Info("hello, world", "response", json.RawMessage(`{"status": "ok", "items": [1, 2]}`))
+
+	m := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	require.Equal(t, map[string]interface{}{
+		"status": "ok",
+		"items":  []interface{}{float64(1), float64(2)},
+	}, m["response"])
+	require.NotContains(t, m, log.InvalidJSONKey)
+}
+
+func TestJSONEncoder_InvalidRawMessage(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	logger.Info("hello, world", "response", json.RawMessage(`{"status": `))
+
+	m := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &m), buf.String())
+	require.Equal(t, `{"status": `, m["response"])
+	require.Equal(t, []interface{}{"response"}, m[log.InvalidJSONKey])
+}
+
+func TestJSONEncoder_EntryAndLineEncodeIdentically(t *testing.T) {
+	kverrors.CaptureStacks(true)
+	err := kverrors.New("an error")
+	kverrors.CaptureStacks(false)
+
+	entry := log.Entry{
+		Timestamp: "2021-01-01T00:00:00Z",
+		Level:     "2",
+		Verbosity: 2,
+		Component: "mycomponent",
+		Message:   "hello, world",
+		Fields: map[string]interface{}{
+			"city":       "Athens",
+			log.ErrorKey: err,
+		},
+		Error:  err,
+		Caller: "encoding_test.go:1",
+		Stack:  kverrors.Stack(err),
+	}
+
+	for _, enc := range []log.JSONEncoder{{}, {StackTrace: true}} {
+		fromEntry := bytes.NewBuffer(nil)
+		require.NoError(t, enc.Encode(fromEntry, entry))
+
+		fromLine := bytes.NewBuffer(nil)
+		require.NoError(t, enc.Encode(fromLine, entry.Line()))
+
+		require.Equal(t, fromLine.String(), fromEntry.String())
+	}
+}
+
+func TestJSONEncoder_ErrorChain(t *testing.T) {
+	root := kverrors.New("connection refused", "host", "db")
+	mid := kverrors.Add(kverrors.Wrap(root, "query failed", "table", "users"), "attempt", 2)
+	top := kverrors.Wrap(mid, "request failed", "path", "/users")
+
+	buf := bytes.NewBuffer(nil)
+	log.NewLogger("", buf, 0, log.JSONEncoder{ErrorChain: true}).Error(top, "hello, world")
+
+	m := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"msg": "request failed", "path": "/users"},
+		map[string]interface{}{"msg": "query failed", "table": "users", "attempt": float64(2)},
+		map[string]interface{}{"msg": "connection refused", "host": "db"},
+	}, m[log.ErrorChainKey])
+}
+
+func TestJSONEncoder_ErrorChain_ForeignWrapper(t *testing.T) {
+	root := kverrors.New("connection refused", "host", "db")
+	err := kverrors.Add(fmt.Errorf("query failed: %w", root), "attempt", 2)
+
+	buf := bytes.NewBuffer(nil)
+	log.NewLogger("", buf, 0, log.JSONEncoder{ErrorChain: true}).Error(err, "hello, world")
+
+	m := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	require.Equal(t, []interface{}{
+		map[string]interface{}{"msg": "query failed: connection refused", "attempt": float64(2)},
+		map[string]interface{}{"msg": "connection refused", "host": "db"},
+	}, m[log.ErrorChainKey])
+}
+
+func TestJSONEncoder_ErrorChain_Disabled(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	log.NewLogger("", buf, 0, log.JSONEncoder{}).Error(kverrors.New("an error"), "hello, world")
+
+	require.NotContains(t, buf.String(), log.ErrorChainKey)
+}
+
+// chunkWriter records every call to Write separately
+type chunkWriter struct {
+	mtx    sync.Mutex
+	chunks [][]byte
+}
+
+func (c *chunkWriter) Write(p []byte) (int, error) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	c.chunks = append(c.chunks, append([]byte{}, p...))
+	return len(p), nil
+}
+
+func TestJSONEncoder_SharedAcrossGoroutines(t *testing.T) {
+	const (
+		workers = 8
+		entries = 200
+	)
+
+	w := &chunkWriter{}
+	enc := log.JSONEncoder{StackTrace: true}
+	loggers := []*log.Logger{
+		log.NewLogger("first", w, 0, enc),
+		log.NewLogger("second", w, 0, enc),
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			logger := loggers[i%len(loggers)].WithValues("worker", i)
+			for j := 0; j < entries; j++ {
+				logger.Info("hello, world", "entry", j, "payload", strings.Repeat("x", j))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Len(t, w.chunks, workers*entries)
+	seen := map[string]bool{}
+	for _, chunk := range w.chunks {
+		require.True(t, bytes.HasSuffix(chunk, []byte("\n")))
+		m := map[string]interface{}{}
+		require.NoError(t, json.Unmarshal(chunk, &m), "corrupted entry: %q", chunk)
+		seen[fmt.Sprintf("%v-%v", m["worker"], m["entry"])] = true
+	}
+	require.Len(t, seen, workers*entries)
+}
+
+func TestJSONEncoder_OmitsEmptyMessage(t *testing.T) {
+	for msg, present := range map[string]bool{"": false, " ": true, "hello": true} {
+		buf := bytes.NewBuffer(nil)
+		log.NewLogger("", buf, 0, log.JSONEncoder{}).Info(msg, "key", "value")
+
+		m := decodeEntry(t, buf.Bytes())
+		v, ok := m[log.MessageKey]
+		require.Equal(t, present, ok, "message %q", msg)
+		if present {
+			require.Equal(t, msg, v)
+		}
+		require.Equal(t, "value", m["key"])
+	}
+}
+
+func TestEncodeEntry(t *testing.T) {
+	b, err := log.EncodeEntry(log.JSONEncoder{}, log.Entry{Level: "0", Message: "hello", Fields: map[string]interface{}{"key": "value"}})
+	require.NoError(t, err)
+	require.True(t, json.Valid(b), string(b))
+	require.Contains(t, string(b), `"key":"value"`)
+}
+
+func TestEncodeEntry_RecoversPanic(t *testing.T) {
+	enc := fakeEncoder{
+		EncodeFunc: func(io.Writer, interface{}) error {
+			panic("boom")
+		},
+	}
+
+	b, err := log.EncodeEntry(enc, log.Entry{})
+	require.Error(t, err)
+	require.Nil(t, b)
+	require.Equal(t, "boom", kverrors.KVs(err)["panic"])
+}
+
+func TestLogger_EncodeFailureIsValidJSON(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	logger.Info("unsupported \x00 value", "value", math.Inf(1))
+
+	require.Contains(t, buf.String(), "failed to encode message")
+	require.True(t, json.Valid(buf.Bytes()), buf.String())
+}
+
+func TestJSONEncoder_ErrorStatus(t *testing.T) {
+	notFound := kverrors.WithStatus(kverrors.New("not found", "id", 42), 404)
+	tests := []struct {
+		desc string
+		err  error
+	}{
+		{desc: "status", err: notFound},
+		{desc: "wrapped", err: kverrors.Wrap(notFound, "failed to get item")},
+		{desc: "plain wrapper", err: fmt.Errorf("handler: %w", notFound)},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			buf := bytes.NewBuffer(nil)
+			logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+			logger.Error(tc.err, "request failed")
+
+			m := decodeEntry(t, buf.Bytes())
+			e, ok := m[log.ErrorKey].(map[string]interface{})
+			require.True(t, ok, "expected an error object, got %v", m[log.ErrorKey])
+			require.EqualValues(t, 404, e[kverrors.StatusKey])
+		})
+	}
+}
+
+func TestJSONEncoder_EntrySize(t *testing.T) {
+	for _, n := range []int{0, 1, 10, 100, 1000} {
+		buf := bytes.NewBuffer(nil)
+		logger := log.NewLogger("test", buf, 0, log.JSONEncoder{EntrySize: true})
+		logger.Info(strings.Repeat("x", n), log.EntrySizeKey, "overwritten")
+
+		m := decodeEntry(t, buf.Bytes())
+		require.EqualValues(t, buf.Len(), m[log.EntrySizeKey])
+		require.True(t, strings.HasSuffix(buf.String(), fmt.Sprintf(`,"%s":%d}`+"\n", log.EntrySizeKey, buf.Len())), buf.String())
+	}
+}
@@ -0,0 +1,50 @@
+package log
+
+import (
+	"runtime"
+	"time"
+)
+
+// Entry is a log entry as passed to an Encoder. Fields may be added to Entry
+// in the future, so encoders should not rely on it being comparable or on
+// its exact set of fields.
+type Entry struct {
+	// Time is the time of the entry
+	Time time.Time
+	// Timestamp is the formatted time of the entry. See TimestampFunc
+	Timestamp string
+	// Level is the named severity of the entry or otherwise its verbosity
+	Level string
+	// Verbosity is the verbosity of the entry
+	Verbosity Verbosity
+	// Component is the name of the logger
+	Component string
+	// Message is the logged message
+	Message string
+	// Fields are all key/value pairs of the entry including the logged
+	// error
+	Fields map[string]interface{}
+	// Labels are the labels of the logger, kept apart from Fields. See
+	// Logger.WithLabels
+	Labels map[string]string
+	// Error is the logged error as passed by the caller, if any, so hooks and
+	// encoders can inspect its chain. It is also part of Fields
+	Error error
+	// Caller is the file and line of the code that logged the entry
+	Caller string
+	// Stack is the stack captured by kverrors when Error was created, if
+	// any. See kverrors.CaptureStacks
+	Stack []runtime.Frame
+}
+
+// Line returns the entry as a Line
+func (e Entry) Line() Line {
+	return Line{
+		Timestamp: e.Timestamp,
+		FileLine:  e.Caller,
+		Verbosity: e.Level,
+		Component: e.Component,
+		Message:   e.Message,
+		Context:   e.Fields,
+	}
+}
@@ -0,0 +1,86 @@
+package log
+
+import (
+	"os"
+	"strings"
+
+	"github.com/ViaQ/logerr/kverrors"
+)
+
+// Environment variables read by InitFromEnv
+const (
+	// EnvLogLevel is the verbosity or a level name, e.g. LOG_LEVEL=2 or
+	// LOG_LEVEL=debug. See ParseLevel
+	EnvLogLevel = "LOG_LEVEL"
+	// EnvLogFormat is one of json (default), console or logfmt
+	EnvLogFormat = "LOG_FORMAT"
+	// EnvLogOutput is one of stdout (default) or stderr
+	EnvLogOutput = "LOG_OUTPUT"
+)
+
+// InitFromEnv inits the logger with the options read from EnvLogLevel,
+// EnvLogFormat and EnvLogOutput. Unset variables keep the defaults of Init.
+// If a variable has an unknown value an error wrapping ErrInvalidOption is
+// returned and the logger is left unchanged.
+func InitFromEnv(component string, keyValuePairs ...interface{}) error {
+	opts, err := optionsFromEnv()
+	if err != nil {
+		return err
+	}
+	return InitE(component, opts, keyValuePairs...)
+}
+
+// optionsFromEnv returns the options configured by the environment
+func optionsFromEnv() ([]Option, error) {
+	var opts []Option
+
+	if v, ok := lookupEnv(EnvLogLevel); ok {
+		level, err := ParseLevel(v)
+		if err != nil {
+			return nil, invalidEnv(EnvLogLevel, v, "must be one of debug, info, warn, error or a non-negative integer")
+		}
+		opts = append(opts, WithLogLevel(level))
+	}
+
+	if v, ok := lookupEnv(EnvLogFormat); ok {
+		switch strings.ToLower(v) {
+		case "json":
+		case "console":
+			opts = append(opts, WithEncoder(ConsoleEncoder{}))
+		case "logfmt":
+			opts = append(opts, WithEncoder(LogfmtEncoder{}))
+		default:
+			return nil, invalidEnv(EnvLogFormat, v, "must be one of json, console or logfmt")
+		}
+	}
+
+	if v, ok := lookupEnv(EnvLogOutput); ok {
+		switch strings.ToLower(v) {
+		case "stdout":
+			opts = append(opts, WithOutput(os.Stdout))
+		case "stderr":
+			opts = append(opts, WithOutput(os.Stderr))
+		default:
+			return nil, invalidEnv(EnvLogOutput, v, "must be one of stdout or stderr")
+		}
+	}
+
+	return opts, nil
+}
+
+// lookupEnv returns the trimmed value of the environment variable key and
+// whether it is set to a non-empty value
+func lookupEnv(key string) (string, bool) {
+	v := strings.TrimSpace(os.Getenv(key))
+	return v, v != ""
+}
+
+// invalidEnv returns the error for an environment variable with an invalid
+// value
+func invalidEnv(key, value, reason string) error {
+	return kverrors.Add(ErrInvalidOption,
+		"env", key,
+		"value", value,
+		"reason", reason,
+	)
+}
@@ -0,0 +1,60 @@
+package log_test
+
+import (
+	"os"
+	"testing"
+
+	"github.com/ViaQ/logerr/kverrors"
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInitFromEnv(t *testing.T) {
+	tests := []struct {
+		format  string
+		output  string
+		encoder log.Encoder
+		writer  *os.File
+	}{
+		{"", "", log.JSONEncoder{}, os.Stdout},
+		{"json", "stdout", log.JSONEncoder{}, os.Stdout},
+		{"console", "stderr", log.ConsoleEncoder{}, os.Stderr},
+		{"LOGFMT", "STDERR", log.LogfmtEncoder{}, os.Stderr},
+	}
+	for _, tt := range tests {
+		t.Run(tt.format+"_"+tt.output, func(t *testing.T) {
+			t.Setenv(log.EnvLogFormat, tt.format)
+			t.Setenv(log.EnvLogOutput, tt.output)
+			require.NoError(t, log.InitFromEnv(t.Name()))
+
+			ll, err := log.Sink()
+			require.NoError(t, err)
+			require.Equal(t, tt.encoder, log.LoggerEncoder(ll))
+			require.Equal(t, tt.writer, log.LoggerOutput(ll))
+		})
+	}
+}
+
+func TestInitFromEnv_LogLevel(t *testing.T) {
+	defer log.SetLogLevel(0)
+	t.Setenv(log.EnvLogLevel, "2")
+	require.NoError(t, log.InitFromEnv(t.Name()))
+
+	require.True(t, log.V(2).Enabled())
+	require.False(t, log.V(3).Enabled())
+}
+
+func TestInitFromEnv_InvalidValues(t *testing.T) {
+	for key, value := range map[string]string{
+		log.EnvLogLevel:  "verbose",
+		log.EnvLogFormat: "xml",
+		log.EnvLogOutput: "/dev/null",
+	} {
+		t.Run(key, func(t *testing.T) {
+			t.Setenv(key, value)
+			err := log.InitFromEnv(t.Name())
+			require.Equal(t, log.ErrInvalidOption, kverrors.Root(err))
+			require.Equal(t, key, kverrors.KVs(err)["env"])
+		})
+	}
+}
@@ -0,0 +1,79 @@
+package log
+
+import (
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// SetExitFunc replaces the function used to exit the process and returns
+// a function that restores the original
+func SetExitFunc(f func(int)) (restore func()) {
+	orig := exitFunc
+	exitFunc = f
+	return func() {
+		exitFunc = orig
+	}
+}
+
+// SetSignalFuncs replaces the functions FlushOnSignal registers for signals
+// and raises them with and returns a function that restores the originals
+func SetSignalFuncs(notify func(chan<- os.Signal, ...os.Signal), raise func(os.Signal)) (restore func()) {
+	origNotify, origRaise := notifyFunc, raiseFunc
+	notifyFunc, raiseFunc = notify, raise
+	return func() {
+		notifyFunc, raiseFunc = origNotify, origRaise
+	}
+}
+
+// ResetDefaultLogger removes the root logger so that the default logger is
+// created again on next use
+func ResetDefaultLogger() {
+	mtx.Lock()
+	defer mtx.Unlock()
+	logger = nil
+	defaultLogger = nil
+	defaultLoggerOnce = sync.Once{}
+}
+
+// SnapshotLevels returns a function that restores the named severities
+// registered with RegisterLevel to those registered now
+func SnapshotLevels() (restore func()) {
+	levelsMtx.RLock()
+	defer levelsMtx.RUnlock()
+	snapshot := make(map[string]int, len(levels))
+	for name, v := range levels {
+		snapshot[name] = v
+	}
+	return func() {
+		levelsMtx.Lock()
+		defer levelsMtx.Unlock()
+		levels = snapshot
+	}
+}
+
+// ResetOnce forgets the keys logged with Once and Every
+func ResetOnce() {
+	onceMtx.Lock()
+	defer onceMtx.Unlock()
+	onceLogged = map[string]struct{}{}
+	everyLogged = map[string]time.Time{}
+}
+
+// ResetLevelChangeFuncs removes all functions registered with OnLevelChange
+func ResetLevelChangeFuncs() {
+	mtx.Lock()
+	defer mtx.Unlock()
+	levelChangeFuncs = nil
+}
+
+// LoggerEncoder returns the encoder of l
+func LoggerEncoder(l *Logger) Encoder {
+	return l.encoder
+}
+
+// LoggerOutput returns the output of l
+func LoggerOutput(l *Logger) io.Writer {
+	return l.output
+}
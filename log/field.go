@@ -0,0 +1,95 @@
+package log
+
+import (
+	"strconv"
+)
+
+// ErrFieldKey is the key of a field created with Err
+const ErrFieldKey = "error"
+
+// fieldType tells which value of a Field is set
+type fieldType uint8
+
+const (
+	stringField fieldType = iota
+	intField
+	interfaceField
+)
+
+// Field is a typed key/value pair. Fields can be passed to Info, Error and
+// WithValues in place of a key and its value and are rendered identically
+// but avoid boxing the value in an interface{} and encoding it with
+// reflection.
+//
+//	logger.Info("connected", log.String("host", host), log.Int("attempt", n))
+type Field struct {
+	Key   string
+	typ   fieldType
+	str   string
+	num   int64
+	iface interface{}
+}
+
+// String returns a field with a string value
+func String(key, value string) Field {
+	return Field{Key: key, typ: stringField, str: value}
+}
+
+// Int returns a field with an int value
+func Int(key string, value int) Field {
+	return Field{Key: key, typ: intField, num: int64(value)}
+}
+
+// Err returns a field holding err under ErrFieldKey
+func Err(err error) Field {
+	return Field{Key: ErrFieldKey, typ: interfaceField, iface: err}
+}
+
+// Value returns the value of the field as an interface{}
+func (f Field) Value() interface{} {
+	switch f.typ {
+	case stringField:
+		return f.str
+	case intField:
+		return int(f.num)
+	default:
+		return f.iface
+	}
+}
+
+// MarshalJSON implements json.Marshaler
+func (f Field) MarshalJSON() ([]byte, error) {
+	return f.appendJSON(nil, true)
+}
+
+// appendJSON appends the JSON encoding of the value of f to b. <, > and & are
+// only escaped if escapeHTML is set
+func (f Field) appendJSON(b []byte, escapeHTML bool) ([]byte, error) {
+	switch f.typ {
+	case stringField:
+		return appendJSONString(b, f.str, escapeHTML), nil
+	case intField:
+		return strconv.AppendInt(b, f.num, 10), nil
+	default:
+		v, err := marshalJSON(f.iface, escapeHTML)
+		if err != nil {
+			return nil, err
+		}
+		return append(b, v...), nil
+	}
+}
+
+// appendJSONString appends s to b as a JSON string. Only strings that need
+// no escaping take the fast path, everything else is left to encoding/json
+// so that the output is identical.
+func appendJSONString(b []byte, s string, escapeHTML bool) []byte {
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c < 0x20 || c >= 0x7f || c == '"' || c == '\\' || c == '<' || c == '>' || c == '&' {
+			v, _ := marshalJSON(s, escapeHTML)
+			return append(b, v...)
+		}
+	}
+	b = append(b, '"')
+	b = append(b, s...)
+	return append(b, '"')
+}
@@ -0,0 +1,63 @@
+package log_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ViaQ/logerr/kverrors"
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestField_RendersLikeKeysAndValues(t *testing.T) {
+	defer func(f func() string) { log.TimestampFunc = f }(log.TimestampFunc)
+	log.TimestampFunc = func() string { return "2021-01-01T00:00:00Z" }
+
+	kvErr := kverrors.New("fail boat", "reason", "sunk")
+	tests := []struct {
+		desc  string
+		typed []interface{}
+		plain []interface{}
+	}{
+		{"string", []interface{}{log.String("city", "Athens")}, []interface{}{"city", "Athens"}},
+		{"escaped string", []interface{}{log.String("html", "<a href=\"x\">\n")}, []interface{}{"html", "<a href=\"x\">\n"}},
+		{"unicode string", []interface{}{log.String("greeting", "γειά σου")}, []interface{}{"greeting", "γειά σου"}},
+		{"int", []interface{}{log.Int("attempt", -42)}, []interface{}{"attempt", -42}},
+		{"error", []interface{}{log.Err(kvErr)}, []interface{}{log.ErrFieldKey, kvErr}},
+		{"plain error", []interface{}{log.Err(errors.New("boom"))}, []interface{}{log.ErrFieldKey, errors.New("boom")}},
+		{"mixed", []interface{}{"zone", "eu-1", log.Int("attempt", 1), "ok", true}, []interface{}{"zone", "eu-1", "attempt", 1, "ok", true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.desc, func(t *testing.T) {
+			typed, plain := &bytes.Buffer{}, &bytes.Buffer{}
+			log.NewLogger("fields", typed, 0, log.JSONEncoder{}).Info("hello", tt.typed...)
+			log.NewLogger("fields", plain, 0, log.JSONEncoder{}).Info("hello", tt.plain...)
+			require.Equal(t, plain.String(), typed.String())
+		})
+	}
+}
+
+func TestField_WithValues(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.NewLogger("fields", buf, 0, log.JSONEncoder{}).WithValues(log.String("zone", "eu-1")).Info("hello")
+
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, "eu-1", entry["zone"])
+}
+
+func TestField_StrictKeys(t *testing.T) {
+	var violations []string
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(&bytes.Buffer{}),
+		log.WithStrictKeys(true),
+		log.WithStrictKeysHandler(func(v string) { violations = append(violations, v) }),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Info("hello", log.String("city", "Athens"), "zone", "eu-1")
+	require.Empty(t, violations)
+
+	log.Info("hello", log.String(log.MessageKey, "oops"))
+	require.Len(t, violations, 1)
+}
@@ -0,0 +1,400 @@
+package log
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+
+	"github.com/ViaQ/logerr/internal/kv"
+)
+
+// errorKey returns the key errors are logged under
+func (l *Logger) errorKey() string {
+	if l.opts.errorKey != "" {
+		return l.opts.errorKey
+	}
+	return ErrorKey
+}
+
+// componentKey returns the key the component is written as
+func (l *Logger) componentKey() string {
+	if enc, ok := l.encoder.(JSONEncoder); ok && enc.ComponentKey != "" {
+		return enc.ComponentKey
+	}
+	return ComponentKey
+}
+
+// isReserved reports whether key is the key of a builtin field
+func (l *Logger) isReserved(key string) bool {
+	_, ok := l.opts.reserved[key]
+	return ok
+}
+
+// namespaced moves all fields that are not builtin fields of context under
+// the field namespace
+func (l *Logger) namespaced(context map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(context))
+	fields := make(map[string]interface{}, len(context))
+	for k, v := range context {
+		if l.isReserved(k) {
+			res[k] = v
+			continue
+		}
+		fields[k] = v
+	}
+	if len(fields) > 0 {
+		res[l.opts.fieldNamespace] = fields
+	}
+	return res
+}
+
+// withFields adds the default and fixed fields to context
+func (l *Logger) withFields(context map[string]interface{}) map[string]interface{} {
+	for k, v := range l.opts.defaultFields {
+		if _, ok := context[k]; !ok {
+			context[k] = v
+		}
+	}
+	for k, v := range l.opts.fixedFields {
+		context[k] = v
+	}
+	return context
+}
+
+// formatValues replaces the values of context with the result of the first
+// value formatter that accepts them
+func (l *Logger) formatValues(context map[string]interface{}) {
+	if len(l.opts.valueFormatters) == 0 {
+		return
+	}
+	for k, v := range context {
+		if f, ok := v.(Field); ok {
+			v = f.Value()
+		}
+		for _, format := range l.opts.valueFormatters {
+			if fv, ok := format(v); ok {
+				context[k] = fv
+				break
+			}
+		}
+	}
+}
+
+// replaced returns context with the functions of WithReplaceField applied to
+// every field in sorted key order
+func (l *Logger) replaced(context map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(context))
+	for _, k := range sortedKeys(context) {
+		key, value, keep := k, context[k], true
+		if f, ok := value.(Field); ok {
+			value = f.Value()
+		}
+		for _, replace := range l.opts.replaceFields {
+			if key, value, keep = replace(key, value); !keep {
+				break
+			}
+		}
+		if keep {
+			res[key] = value
+		}
+	}
+	return res
+}
+
+// allowed removes the fields of context that are neither builtin fields nor
+// allowed by WithAllowedKeys
+func (l *Logger) allowed(context map[string]interface{}) {
+	for k := range context {
+		if !l.opts.allowedKeys[k] && !l.isReserved(k) {
+			delete(context, k)
+		}
+	}
+}
+
+// truncated returns context with at most maxFields fields that are not
+// builtin fields. The fields that sort first are kept and FieldsTruncatedKey
+// is set if any were dropped
+func (l *Logger) truncated(context map[string]interface{}) map[string]interface{} {
+	n := 0
+	for k := range context {
+		if !l.isReserved(k) {
+			n++
+		}
+	}
+	if n <= l.opts.maxFields {
+		return context
+	}
+
+	res := make(map[string]interface{}, l.opts.maxFields+1)
+	kept := 0
+	for _, k := range sortedKeys(context) {
+		if l.isReserved(k) {
+			res[k] = context[k]
+			continue
+		}
+		if kept < l.opts.maxFields {
+			res[k] = context[k]
+			kept++
+		}
+	}
+	res[FieldsTruncatedKey] = true
+	return res
+}
+
+// truncateArrays replaces the slices and arrays of context, including those of
+// nested maps, that have more than maxArrayElements elements by their first
+// maxArrayElements elements followed by a marker of how many were left out
+func (l *Logger) truncateArrays(context map[string]interface{}) {
+	for k, v := range context {
+		if f, ok := v.(Field); ok {
+			v = f.Value()
+		}
+		if m, ok := v.(map[string]interface{}); ok {
+			nested := make(map[string]interface{}, len(m))
+			for nk, nv := range m {
+				nested[nk] = nv
+			}
+			l.truncateArrays(nested)
+			context[k] = nested
+			continue
+		}
+		if tv, ok := truncateArray(v, l.opts.maxArrayElements); ok {
+			context[k] = tv
+		}
+	}
+}
+
+// truncateArray returns the first n elements of v followed by the marker
+// "...(+k more)" if v is a slice or array, other than a byte slice, with k > 0
+// more elements
+func truncateArray(v interface{}, n int) (interface{}, bool) {
+	rv := reflect.ValueOf(v)
+	switch rv.Kind() {
+	case reflect.Slice, reflect.Array:
+	default:
+		return nil, false
+	}
+	if rv.Type().Elem().Kind() == reflect.Uint8 || rv.Len() <= n {
+		return nil, false
+	}
+
+	res := make([]interface{}, 0, n+1)
+	for i := 0; i < n; i++ {
+		res = append(res, rv.Index(i).Interface())
+	}
+	return append(res, fmt.Sprintf("...(+%d more)", rv.Len()-n)), true
+}
+
+// formatFloats replaces the floats of context, including those in nested maps,
+// slices and arrays, by json.Numbers formatted with floatNotation. NaN and
+// infinities are left alone as they have no JSON representation. The fields
+// of structs and values that marshal themselves are left to encoding/json.
+func (l *Logger) formatFloats(context map[string]interface{}) {
+	for k, v := range context {
+		context[k] = formatFloat(v, l.opts.floatNotation)
+	}
+}
+
+// formatFloat returns v with its floats formatted in notation. See
+// formatFloats
+func formatFloat(v interface{}, notation byte) interface{} {
+	switch x := v.(type) {
+	case float32:
+		return floatNumber(float64(x), notation, 32)
+	case float64:
+		return floatNumber(x, notation, 64)
+	case []float64:
+		res := make([]interface{}, len(x))
+		for i, f := range x {
+			res[i] = floatNumber(f, notation, 64)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(x))
+		for i, e := range x {
+			res[i] = formatFloat(e, notation)
+		}
+		return res
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(x))
+		for k, e := range x {
+			res[k] = formatFloat(e, notation)
+		}
+		return res
+	}
+
+	rv := reflect.ValueOf(v)
+	if !rv.IsValid() || marshalsItself(rv.Type()) {
+		return v
+	}
+	switch rv.Kind() {
+	case reflect.Float32:
+		return floatNumber(rv.Float(), notation, 32)
+	case reflect.Float64:
+		return floatNumber(rv.Float(), notation, 64)
+	case reflect.Slice, reflect.Array:
+		if rv.Kind() == reflect.Slice && rv.IsNil() || !mayHoldFloats(rv.Type().Elem()) {
+			return v
+		}
+		res := make([]interface{}, rv.Len())
+		for i := range res {
+			res[i] = formatFloat(rv.Index(i).Interface(), notation)
+		}
+		return res
+	case reflect.Map:
+		if rv.Type().Key().Kind() != reflect.String || rv.IsNil() || !mayHoldFloats(rv.Type().Elem()) {
+			return v
+		}
+		res := make(map[string]interface{}, rv.Len())
+		iter := rv.MapRange()
+		for iter.Next() {
+			res[iter.Key().String()] = formatFloat(iter.Value().Interface(), notation)
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+// marshalsItself reports whether encoding/json encodes values of t with their
+// own MarshalJSON or MarshalText method
+func marshalsItself(t reflect.Type) bool {
+	return t.Implements(marshalerType) || t.Implements(textMarshalerType)
+}
+
+// mayHoldFloats reports whether values of t are or may contain floats that
+// formatFloat formats
+func mayHoldFloats(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Float32, reflect.Float64, reflect.Interface, reflect.Slice, reflect.Array, reflect.Map:
+		return true
+	default:
+		return false
+	}
+}
+
+// floatNumber formats f in notation unless it is NaN or infinite
+func floatNumber(f float64, notation byte, bitSize int) interface{} {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return f
+	}
+	return json.Number(strconv.FormatFloat(f, notation, -1, bitSize))
+}
+
+// quoteNumbers replaces numbers in context by their string representation as
+// configured by WithNumbersAsStrings and WithBuiltinNumbersAsStrings
+func (l *Logger) quoteNumbers(context map[string]interface{}) {
+	for k, v := range context {
+		if reserved := l.isReserved(k); reserved && !l.opts.builtinNumbersAsStrings || !reserved && !l.opts.numbersAsStrings {
+			continue
+		}
+		if s, ok := numberString(v); ok {
+			context[k] = s
+		}
+	}
+}
+
+// numberString returns the decimal representation of v if it is a number
+func numberString(v interface{}) (string, bool) {
+	switch n := v.(type) {
+	case Field:
+		if n.typ == intField {
+			return strconv.FormatInt(n.num, 10), true
+		}
+		return numberString(n.Value())
+	case int:
+		return strconv.FormatInt(int64(n), 10), true
+	case int8:
+		return strconv.FormatInt(int64(n), 10), true
+	case int16:
+		return strconv.FormatInt(int64(n), 10), true
+	case int32:
+		return strconv.FormatInt(int64(n), 10), true
+	case int64:
+		return strconv.FormatInt(n, 10), true
+	case uint:
+		return strconv.FormatUint(uint64(n), 10), true
+	case uint8:
+		return strconv.FormatUint(uint64(n), 10), true
+	case uint16:
+		return strconv.FormatUint(uint64(n), 10), true
+	case uint32:
+		return strconv.FormatUint(uint64(n), 10), true
+	case uint64:
+		return strconv.FormatUint(n, 10), true
+	case float32:
+		return strconv.FormatFloat(float64(n), 'g', -1, 32), true
+	case float64:
+		return strconv.FormatFloat(n, 'g', -1, 64), true
+	case json.Number:
+		return n.String(), true
+	}
+	return "", false
+}
+
+// mergeFields returns a new map with the entries of m and keysAndValues
+func mergeFields(m map[string]interface{}, keysAndValues ...interface{}) map[string]interface{} {
+	res := kv.ToMap(keysAndValues...)
+	for k, v := range m {
+		if _, ok := res[k]; !ok {
+			res[k] = v
+		}
+	}
+	return res
+}
+
+// combine combines the context of l with keysAndValues. If strict keys are
+// enabled, violations are reported and listed under StrictKeysKey
+func (l *Logger) combine(keysAndValues ...interface{}) map[string]interface{} {
+	context := nest(l.context, l.groups, keysAndValues)
+	if !l.opts.strictKeys {
+		return context
+	}
+
+	violations := append(append([]string{}, l.violations...), l.checkKeys(keysAndValues)...)
+	if len(violations) > 0 {
+		context[StrictKeysKey] = violations
+	}
+	return context
+}
+
+// checkKeys returns the violations of strict keys in keysAndValues and
+// reports them to the strict keys handler
+func (l *Logger) checkKeys(keysAndValues []interface{}) []string {
+	var violations []string
+	pairs := 0
+	for _, v := range keysAndValues {
+		if _, ok := v.(Field); !ok {
+			pairs++
+		}
+	}
+	if pairs%2 != 0 {
+		violations = append(violations, fmt.Sprintf("odd number of arguments: %d", len(keysAndValues)))
+	}
+	for i := 0; i < len(keysAndValues); i += 2 {
+		if f, ok := keysAndValues[i].(Field); ok {
+			if l.isReserved(f.Key) {
+				violations = append(violations, fmt.Sprintf("key %q collides with a builtin field", f.Key))
+			}
+			i--
+			continue
+		}
+		key, ok := keysAndValues[i].(string)
+		if !ok {
+			violations = append(violations, fmt.Sprintf("key at index %d is not a string: %T", i, keysAndValues[i]))
+			continue
+		}
+		if l.isReserved(key) {
+			violations = append(violations, fmt.Sprintf("key %q collides with a builtin field", key))
+		}
+	}
+
+	if l.opts.strictKeysHandler != nil {
+		for _, v := range violations {
+			l.opts.strictKeysHandler(v)
+		}
+	}
+	return violations
+}
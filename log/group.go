@@ -0,0 +1,43 @@
+package log
+
+import (
+	"github.com/go-logr/logr"
+)
+
+// WithGroup returns a logger that nests the key/value pairs added after it,
+// with WithValues or when logging, under name. See Logger.WithGroup. If the
+// root logger is not *log.Logger it is returned as it is.
+func WithGroup(name string) logr.Logger {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	if ll, ok := root().(*Logger); ok {
+		return ll.WithGroup(name)
+	}
+	return root()
+}
+
+// WithGroup returns a copy of l that nests the key/value pairs added after it,
+// with WithValues or when logging, under name. Groups nest, so
+// l.WithGroup("a").WithGroup("b").Info("msg", "k", "v") logs
+// {"a":{"b":{"k":"v"}}}. Values added to l before are not affected, and an
+// empty name is ignored like in WithName.
+func (l *Logger) WithGroup(name string) logr.Logger {
+	ll := l.clone()
+	if name != "" {
+		ll.groups = append(append([]string{}, l.groups...), name)
+	}
+	return ll
+}
+
+// nest returns a copy of m with keysAndValues combined into the map nested
+// under the path of groups, creating the maps of the groups as needed. The
+// maps of m are copied rather than modified
+func nest(m map[string]interface{}, groups []string, keysAndValues []interface{}) map[string]interface{} {
+	if len(groups) == 0 {
+		return combine(m, keysAndValues...)
+	}
+	res := combine(m)
+	inner, _ := res[groups[0]].(map[string]interface{})
+	res[groups[0]] = nest(inner, groups[1:], keysAndValues)
+	return res
+}
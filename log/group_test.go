@@ -0,0 +1,50 @@
+package log_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithGroup_NestsValues(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf)})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	logger := log.WithValues("request", "r-1").(*log.Logger)
+	logger = logger.WithGroup("http").WithValues("method", "GET").(*log.Logger)
+	logger.WithGroup("response").WithValues("status", 200).Info("served", "bytes", 42)
+
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, "r-1", entry["request"])
+	require.Equal(t, map[string]interface{}{
+		"method": "GET",
+		"response": map[string]interface{}{
+			"status": float64(200),
+			"bytes":  float64(42),
+		},
+	}, entry["http"])
+}
+
+func TestWithGroup_DoesNotChangeParent(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf)})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	parent := log.WithGroup("db").WithValues("table", "users")
+	parent.(*log.Logger).WithGroup("query").Info("child", "rows", 1)
+	child := decodeEntry(t, buf.Bytes())
+	require.Equal(t, map[string]interface{}{
+		"table": "users",
+		"query": map[string]interface{}{"rows": float64(1)},
+	}, child["db"])
+
+	buf.Reset()
+	parent.Info("parent", "rows", 2)
+	require.Equal(t, map[string]interface{}{
+		"table": "users",
+		"rows":  float64(2),
+	}, decodeEntry(t, buf.Bytes())["db"])
+}
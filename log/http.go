@@ -0,0 +1,275 @@
+package log
+
+import (
+	"bufio"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ViaQ/logerr/kverrors"
+	"github.com/go-logr/logr"
+)
+
+// Keys used by HTTPRequestFields and HTTPResponseFields
+const (
+	HTTPMethodKey        = "http_method"
+	HTTPURLKey           = "http_url"
+	HTTPStatusKey        = "http_status"
+	HTTPContentLengthKey = "http_content_length"
+	HTTPHeadersKey       = "http_headers"
+	HTTPPathKey          = "http_path"
+	HTTPRequestIDKey     = "request_id"
+)
+
+// HTTPRequestIDHeader is the header WithRequest takes the request id from
+const HTTPRequestIDHeader = "X-Request-Id"
+
+// RedactedValue replaces values that must not be logged
+const RedactedValue = "[REDACTED]"
+
+var (
+	// HTTPHeaderAllowlist are the headers included by HTTPRequestFields and
+	// HTTPResponseFields. All other headers are left out.
+	HTTPHeaderAllowlist = []string{
+		"Accept",
+		"Authorization",
+		"Content-Type",
+		"Cookie",
+		"Location",
+		"Set-Cookie",
+		"User-Agent",
+		"X-Forwarded-For",
+		"X-Request-Id",
+	}
+
+	// HTTPRedactedHeaders are the headers whose values are replaced by
+	// RedactedValue when they are included by HTTPHeaderAllowlist
+	HTTPRedactedHeaders = []string{
+		"Authorization",
+		"Cookie",
+		"Proxy-Authorization",
+		"Set-Cookie",
+	}
+)
+
+// HTTPRequestFields returns key/value pairs describing r that can be passed
+// to Info or Error. Passwords in the URL, the values of its query and
+// sensitive headers are redacted.
+func HTTPRequestFields(r *http.Request) []interface{} {
+	if r == nil {
+		return nil
+	}
+	fields := []interface{}{
+		HTTPMethodKey, r.Method,
+		HTTPContentLengthKey, r.ContentLength,
+	}
+	if r.URL != nil {
+		fields = append(fields, HTTPURLKey, redactedURL(r.URL))
+	}
+	if headers := httpHeaders(r.Header); len(headers) > 0 {
+		fields = append(fields, HTTPHeadersKey, headers)
+	}
+	return fields
+}
+
+// redactedURL returns u with its password and the values of its query
+// replaced since the query often carries tokens
+func redactedURL(u *url.URL) string {
+	query := u.Query()
+	if len(query) == 0 {
+		return u.Redacted()
+	}
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, url.QueryEscape(k)+"="+RedactedValue)
+	}
+	sort.Strings(keys)
+
+	redacted := *u
+	redacted.RawQuery = strings.Join(keys, "&")
+	return redacted.Redacted()
+}
+
+// WithRequest returns a logger with the method and path of r and its request
+// id under HTTPMethodKey, HTTPPathKey and HTTPRequestIDKey. The request id is
+// taken from the HTTPRequestIDHeader and generated if r has none. The query
+// is left out of the path since it may carry secrets.
+func WithRequest(r *http.Request) logr.Logger {
+	id := r.Header.Get(HTTPRequestIDHeader)
+	if id == "" {
+		id = newRequestID()
+	}
+	path := ""
+	if r.URL != nil {
+		path = r.URL.Path
+	}
+	return WithValues(HTTPMethodKey, r.Method, HTTPPathKey, path, HTTPRequestIDKey, id)
+}
+
+// newRequestID returns a random 128 bit id in hex
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// HTTPResponseFields returns key/value pairs describing resp that can be
+// passed to Info or Error. Sensitive headers are redacted.
+func HTTPResponseFields(resp *http.Response) []interface{} {
+	if resp == nil {
+		return nil
+	}
+	fields := []interface{}{
+		HTTPStatusKey, resp.StatusCode,
+		HTTPContentLengthKey, resp.ContentLength,
+	}
+	if headers := httpHeaders(resp.Header); len(headers) > 0 {
+		fields = append(fields, HTTPHeadersKey, headers)
+	}
+	return fields
+}
+
+// httpHeaders returns the allowed headers of h with sensitive values redacted
+func httpHeaders(h http.Header) map[string]string {
+	headers := map[string]string{}
+	for _, name := range HTTPHeaderAllowlist {
+		name = http.CanonicalHeaderKey(name)
+		value := h.Get(name)
+		if value == "" {
+			continue
+		}
+		for _, redacted := range HTTPRedactedHeaders {
+			if http.CanonicalHeaderKey(redacted) == name {
+				value = RedactedValue
+				break
+			}
+		}
+		headers[name] = value
+	}
+	return headers
+}
+
+// MiddlewareOption configures the handler returned by Middleware
+type MiddlewareOption func(*middleware)
+
+// WithRecover recovers panics of the handler wrapped by Middleware, logs them
+// with Error together with the request fields, with the recovered value and
+// the stack at the time of the panic under the "panic" and "stack" keys of
+// the error, and responds with 500 Internal Server Error if nothing was
+// written yet. If the handler already wrote a response the status it wrote
+// is logged instead of 500. It is enabled by default. When disabled, panics are left to
+// the server or an outer middleware. http.ErrAbortHandler is never recovered.
+func WithRecover(enabled bool) MiddlewareOption {
+	return func(m *middleware) {
+		m.recover = enabled
+	}
+}
+
+// middleware is the handler returned by Middleware
+type middleware struct {
+	next    http.Handler
+	recover bool
+}
+
+// Middleware returns a handler that calls next and logs every request once it
+// was handled with the fields of WithRequest, the status under HTTPStatusKey
+// and the time it took under DurationMillisKey. See WithRecover
+func Middleware(next http.Handler, opts ...MiddlewareOption) http.Handler {
+	m := &middleware{next: next, recover: true}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+// ServeHTTP implements http.Handler
+func (m *middleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	logger := WithRequest(r)
+	rec := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	start := time.Now()
+
+	handled := false
+	defer func() {
+		if handled || !m.recover {
+			return
+		}
+		p := recover()
+		if p == nil {
+			return
+		}
+		if p == http.ErrAbortHandler {
+			panic(p)
+		}
+		status := http.StatusInternalServerError
+		if rec.wroteHeader {
+			status = rec.status
+		}
+		err := kverrors.New("handler panicked", "panic", fmt.Sprint(p), "stack", string(debug.Stack()))
+		logger.Error(err, "request failed",
+			HTTPStatusKey, status,
+			DurationMillisKey, time.Since(start).Milliseconds(),
+		)
+		if !rec.wroteHeader {
+			http.Error(rec, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}()
+
+	m.next.ServeHTTP(rec, r)
+	handled = true
+	logger.Info("request handled",
+		HTTPStatusKey, rec.status,
+		DurationMillisKey, time.Since(start).Milliseconds(),
+	)
+}
+
+// statusRecorder records the status written to a ResponseWriter
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+// WriteHeader records status and writes it
+func (s *statusRecorder) WriteHeader(status int) {
+	if !s.wroteHeader {
+		s.status, s.wroteHeader = status, true
+	}
+	s.ResponseWriter.WriteHeader(status)
+}
+
+// Write writes p, which implies the status 200 if none was written
+func (s *statusRecorder) Write(p []byte) (int, error) {
+	s.wroteHeader = true
+	return s.ResponseWriter.Write(p)
+}
+
+// Flush flushes the wrapped ResponseWriter if it is an http.Flusher
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		s.wroteHeader = true
+		f.Flush()
+	}
+}
+
+// Hijack hijacks the connection of the wrapped ResponseWriter if it is an
+// http.Hijacker
+func (s *statusRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := s.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, kverrors.New("response writer does not support hijacking")
+	}
+	s.wroteHeader = true
+	return h.Hijack()
+}
+
+// Unwrap returns the wrapped ResponseWriter for http.ResponseController
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
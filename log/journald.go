@@ -0,0 +1,220 @@
+package log
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// JournaldSocket is the path of the socket of journald's native protocol
+const JournaldSocket = "/run/systemd/journal/socket"
+
+// Journald fields written by the JournaldEncoder in addition to the fields
+// of the entry
+const (
+	JournaldMessageKey   = "MESSAGE"
+	JournaldPriorityKey  = "PRIORITY"
+	JournaldComponentKey = "COMPONENT"
+	JournaldLevelKey     = "LEVEL"
+	JournaldFileKey      = "CODE_FILE"
+	JournaldLineKey      = "CODE_LINE"
+)
+
+// Syslog priorities entries are logged to journald with
+const (
+	JournaldPriorityError  = 3
+	JournaldPriorityNotice = 5
+	JournaldPriorityInfo   = 6
+	JournaldPriorityDebug  = 7
+)
+
+// WithJournald sends entries to journald using its native protocol. It is
+// the same as WithEncoder(JournaldEncoder{}) with the output set to a
+// JournaldWriter of JournaldSocket. The batching options must not be used
+// with WithJournald as each entry has to be sent as a datagram of its own.
+func WithJournald() Option {
+	return func(l *Logger) {
+		l.encoder = JournaldEncoder{}
+		l.updateReserved()
+		l.SetOutput(NewJournaldWriter(JournaldSocket))
+	}
+}
+
+// JournaldEncoder encodes entries in journald's native protocol. The message,
+// priority, component, level and the file and line of the caller are written
+// under the Journald*Key fields followed by the fields of the entry. Field
+// names are uppercased and any character other than A-Z, 0-9 and '_' is
+// replaced by '_'. Names that collide with a Journald*Key field are prefixed
+// with "F_" so that fields cannot override them. Values
+// containing a newline are framed with their length as required by the
+// protocol.
+type JournaldEncoder struct{}
+
+// Encode encodes the entry in journald's native protocol to w in a single
+// Write
+func (JournaldEncoder) Encode(w io.Writer, entry interface{}) error {
+	m, ok := entry.(Entry)
+	if !ok {
+		return unsupportedEntry(entry)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	writeJournaldField(buf, JournaldMessageKey, m.Message)
+	writeJournaldField(buf, JournaldPriorityKey, strconv.Itoa(journaldPriority(m)))
+	if m.Component != "" {
+		writeJournaldField(buf, JournaldComponentKey, m.Component)
+	}
+	writeJournaldField(buf, JournaldLevelKey, m.Level)
+	if i := strings.LastIndexByte(m.Caller, ':'); i > 0 {
+		writeJournaldField(buf, JournaldFileKey, m.Caller[:i])
+		writeJournaldField(buf, JournaldLineKey, m.Caller[i+1:])
+	}
+	for _, k := range sortedKeys(m.Fields) {
+		writeJournaldField(buf, journaldFieldName(k), journaldValue(m.Fields[k]))
+	}
+
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// journaldPriority maps the severity of m to a syslog priority
+func journaldPriority(m Entry) int {
+	switch {
+	case m.Error != nil:
+		return JournaldPriorityError
+	case strings.EqualFold(m.Level, AuditLevel) || strings.EqualFold(m.Level, SecurityLevel):
+		return JournaldPriorityNotice
+	case m.Verbosity > 0:
+		return JournaldPriorityDebug
+	default:
+		return JournaldPriorityInfo
+	}
+}
+
+// writeJournaldField writes key and value to buf. Values without newlines are
+// written as KEY=value\n, other values as KEY\n followed by the length of the
+// value as a little endian uint64, the value and \n.
+func writeJournaldField(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+
+	var size [8]byte
+	binary.LittleEndian.PutUint64(size[:], uint64(len(value)))
+	buf.WriteByte('\n')
+	buf.Write(size[:])
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journaldFieldName converts key to a valid journald field name. Leading
+// underscores are dropped because journald reserves them for trusted fields,
+// and names that would not start with a letter or that collide with the
+// fields written by the JournaldEncoder are prefixed with "F_".
+func journaldFieldName(key string) string {
+	b := make([]byte, 0, len(key)+1)
+	for i := 0; i < len(key); i++ {
+		c := key[i]
+		switch {
+		case 'a' <= c && c <= 'z':
+			c -= 'a' - 'A'
+		case 'A' <= c && c <= 'Z', '0' <= c && c <= '9':
+		default:
+			c = '_'
+		}
+		if c == '_' && len(b) == 0 {
+			continue
+		}
+		b = append(b, c)
+	}
+	if len(b) == 0 || b[0] < 'A' || b[0] > 'Z' || isJournaldKey(string(b)) {
+		b = append([]byte{'F', '_'}, b...)
+	}
+	if len(b) > 64 {
+		b = b[:64]
+	}
+	return string(b)
+}
+
+// journaldValue formats v as a journald value. Strings and errors are written
+// as they are, everything else as JSON
+func journaldValue(v interface{}) string {
+	if f, ok := v.(Field); ok {
+		v = f.Value()
+	}
+	switch x := v.(type) {
+	case string:
+		return x
+	case error:
+		return x.Error()
+	default:
+		return jsonValue(x)
+	}
+}
+
+// JournaldWriter writes each Write as a datagram to a journald socket. It
+// connects on the first Write and reconnects after a failed Write, so it can
+// be created before journald is available.
+type JournaldWriter struct {
+	mtx  sync.Mutex
+	path string
+	conn net.Conn
+}
+
+// NewJournaldWriter creates a JournaldWriter for the socket at path, usually
+// JournaldSocket
+func NewJournaldWriter(path string) *JournaldWriter {
+	return &JournaldWriter{path: path}
+}
+
+// Write sends p to journald as a single datagram
+func (j *JournaldWriter) Write(p []byte) (int, error) {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	if j.conn == nil {
+		conn, err := net.Dial("unixgram", j.path)
+		if err != nil {
+			return 0, err
+		}
+		j.conn = conn
+	}
+
+	n, err := j.conn.Write(p)
+	if err != nil {
+		_ = j.conn.Close()
+		j.conn = nil
+	}
+	return n, err
+}
+
+// Close closes the connection to journald
+func (j *JournaldWriter) Close() error {
+	j.mtx.Lock()
+	defer j.mtx.Unlock()
+
+	if j.conn == nil {
+		return nil
+	}
+	err := j.conn.Close()
+	j.conn = nil
+	return err
+}
+
+// isJournaldKey reports whether name is one of the fields written by the
+// JournaldEncoder
+func isJournaldKey(name string) bool {
+	switch name {
+	case JournaldMessageKey, JournaldPriorityKey, JournaldComponentKey, JournaldLevelKey, JournaldFileKey, JournaldLineKey:
+		return true
+	}
+	return false
+}
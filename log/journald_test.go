@@ -0,0 +1,165 @@
+package log_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+// parseJournald parses the fields of a datagram of journald's native protocol
+func parseJournald(t *testing.T, b []byte) map[string]string {
+	fields := map[string]string{}
+	for len(b) > 0 {
+		i := bytes.IndexAny(b, "=\n")
+		require.NotEqual(t, -1, i, "unterminated field name")
+		key := string(b[:i])
+
+		if b[i] == '=' {
+			b = b[i+1:]
+			j := bytes.IndexByte(b, '\n')
+			require.NotEqual(t, -1, j, "unterminated value of %s", key)
+			fields[key] = string(b[:j])
+			b = b[j+1:]
+			continue
+		}
+
+		b = b[i+1:]
+		require.GreaterOrEqual(t, len(b), 8, "missing length of %s", key)
+		size := int(binary.LittleEndian.Uint64(b[:8]))
+		b = b[8:]
+		require.Greater(t, len(b), size, "short value of %s", key)
+		require.Equal(t, byte('\n'), b[size], "unterminated value of %s", key)
+		fields[key] = string(b[:size])
+		b = b[size+1:]
+	}
+	return fields
+}
+
+func TestJournaldEncoder_Fields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := log.JournaldEncoder{}.Encode(buf, log.Entry{
+		Level:     "0",
+		Component: "test",
+		Message:   "hello",
+		Fields: map[string]interface{}{
+			"user-name": "alice",
+			"_secret":   "x",
+			"1count":    2,
+		},
+	})
+	require.NoError(t, err)
+
+	fields := parseJournald(t, buf.Bytes())
+	require.Equal(t, map[string]string{
+		"MESSAGE":   "hello",
+		"PRIORITY":  "6",
+		"COMPONENT": "test",
+		"LEVEL":     "0",
+		"USER_NAME": "alice",
+		"SECRET":    "x",
+		"F_1COUNT":  "2",
+	}, fields)
+}
+
+func TestJournaldEncoder_CollidingFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	err := log.JournaldEncoder{}.Encode(buf, log.Entry{
+		Level:     "0",
+		Component: "test",
+		Message:   "hello",
+		Caller:    "pkg/file.go:42",
+		Fields: map[string]interface{}{
+			"message":   "spoofed",
+			"priority":  "0",
+			"component": "other",
+			"level":     "9",
+			"code_line": "1",
+		},
+	})
+	require.NoError(t, err)
+
+	fields := parseJournald(t, buf.Bytes())
+	require.Equal(t, map[string]string{
+		"MESSAGE":     "hello",
+		"PRIORITY":    "6",
+		"COMPONENT":   "test",
+		"LEVEL":       "0",
+		"CODE_FILE":   "pkg/file.go",
+		"CODE_LINE":   "42",
+		"F_MESSAGE":   "spoofed",
+		"F_PRIORITY":  "0",
+		"F_COMPONENT": "other",
+		"F_LEVEL":     "9",
+		"F_CODE_LINE": "1",
+	}, fields)
+}
+
+func TestJournaldEncoder_MultilineFraming(t *testing.T) {
+	buf := &bytes.Buffer{}
+	msg := "first\nsecond"
+	require.NoError(t, log.JournaldEncoder{}.Encode(buf, log.Entry{Message: msg}))
+
+	b := buf.Bytes()
+	prefix := "MESSAGE\n"
+	require.Equal(t, prefix, string(b[:len(prefix)]))
+	b = b[len(prefix):]
+	require.Equal(t, uint64(len(msg)), binary.LittleEndian.Uint64(b[:8]))
+	require.Equal(t, msg+"\n", string(b[8:8+len(msg)+1]))
+
+	require.Equal(t, msg, parseJournald(t, buf.Bytes())["MESSAGE"])
+}
+
+func TestJournaldEncoder_Priority(t *testing.T) {
+	tests := []struct {
+		desc     string
+		entry    log.Entry
+		priority string
+	}{
+		{desc: "info", entry: log.Entry{Verbosity: 0}, priority: "6"},
+		{desc: "debug", entry: log.Entry{Verbosity: 2}, priority: "7"},
+		{desc: "audit", entry: log.Entry{Level: log.AuditLevel}, priority: "5"},
+		{desc: "error", entry: log.Entry{Error: errors.New("fail boat")}, priority: "3"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			require.NoError(t, log.JournaldEncoder{}.Encode(buf, tc.entry))
+			require.Equal(t, tc.priority, parseJournald(t, buf.Bytes())["PRIORITY"])
+		})
+	}
+}
+
+func TestJournaldWriter_SendsDatagrams(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("unixgram sockets are not supported")
+	}
+
+	path := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	w := log.NewJournaldWriter(path)
+	defer func() { require.NoError(t, w.Close()) }()
+
+	logger := log.NewLogger("test", w, 0, log.JournaldEncoder{})
+	logger.Info("hello", "user", "alice")
+
+	require.NoError(t, conn.SetReadDeadline(time.Now().Add(time.Second)))
+	b := make([]byte, 4096)
+	n, err := conn.Read(b)
+	require.NoError(t, err)
+
+	fields := parseJournald(t, b[:n])
+	require.Equal(t, "hello", fields["MESSAGE"])
+	require.Equal(t, "alice", fields["USER"])
+	require.Equal(t, "test", fields["COMPONENT"])
+}
@@ -0,0 +1,46 @@
+package log
+
+import (
+	"fmt"
+
+	"github.com/go-logr/logr"
+)
+
+// LabelsKey is the key the JSONEncoder nests the labels of an entry under
+const LabelsKey = "_labels"
+
+// WithLabels returns a logger whose entries carry keysAndValues as labels,
+// see Logger.WithLabels. If the root logger is not *log.Logger the labels are
+// added as a value under LabelsKey instead.
+func WithLabels(keysAndValues ...interface{}) logr.Logger {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	if ll, ok := root().(*Logger); ok {
+		return ll.WithLabels(keysAndValues...)
+	}
+	return root().WithValues(LabelsKey, addLabels(nil, keysAndValues...))
+}
+
+// WithLabels returns a copy of l whose entries carry keysAndValues as labels
+// in addition to the labels of l. Labels are kept apart from the fields of an
+// entry for backends that index them, like the stream labels of Loki. Values
+// are formatted as strings. The JSONEncoder nests them under LabelsKey, see
+// Entry.Labels.
+func (l *Logger) WithLabels(keysAndValues ...interface{}) logr.Logger {
+	ll := l.clone()
+	ll.labels = addLabels(l.labels, keysAndValues...)
+	return ll
+}
+
+// addLabels returns a copy of labels with keysAndValues added. A trailing key
+// without a value is ignored
+func addLabels(labels map[string]string, keysAndValues ...interface{}) map[string]string {
+	res := make(map[string]string, len(labels)+len(keysAndValues)/2)
+	for k, v := range labels {
+		res[k] = v
+	}
+	for i := 0; i+1 < len(keysAndValues); i += 2 {
+		res[fmt.Sprint(keysAndValues[i])] = fmt.Sprint(keysAndValues[i+1])
+	}
+	return res
+}
@@ -0,0 +1,63 @@
+package log_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"testing"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithLabels_UserLabelsKey(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf), log.WithStrictKeys(true)})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.WithLabels("app", "billing").Info("hello", "labels", "user")
+
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, "user", entry["labels"])
+	require.Equal(t, map[string]interface{}{"app": "billing"}, entry[log.LabelsKey])
+	require.NotContains(t, entry, log.StrictKeysKey)
+}
+
+func TestWithLabels_SegregatedFromFields(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf), log.WithFieldNamespace("fields")})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	logger := log.WithLabels("app", "billing", "env", "prod").WithValues("user", "alice")
+	logger.(*log.Logger).WithLabels("env", "staging", "shard", 3).Info("hello", "city", "Athens")
+
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, map[string]interface{}{
+		"app":   "billing",
+		"env":   "staging",
+		"shard": "3",
+	}, entry[log.LabelsKey])
+	require.Equal(t, map[string]interface{}{
+		"user": "alice",
+		"city": "Athens",
+	}, entry["fields"])
+}
+
+func TestWithLabels_DoesNotChangeParent(t *testing.T) {
+	var labels []map[string]string
+	enc := fakeEncoder{
+		EncodeFunc: func(_ io.Writer, entry interface{}) error {
+			labels = append(labels, entry.(log.Entry).Labels)
+			return nil
+		},
+	}
+	logger := log.NewLogger("", ioutil.Discard, 0, enc)
+	child := logger.WithLabels("app", "billing")
+
+	logger.Info("parent")
+	child.Info("child")
+
+	require.Len(t, labels, 2)
+	require.Empty(t, labels[0])
+	require.Equal(t, map[string]string{"app": "billing"}, labels[1])
+}
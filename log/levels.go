@@ -0,0 +1,172 @@
+package log
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ViaQ/logerr/kverrors"
+	"github.com/go-logr/logr"
+)
+
+// AuditTypeKey and AuditTypeValue mark the entries of the audit logger
+const (
+	AuditTypeKey   = "type"
+	AuditTypeValue = "audit"
+)
+
+// Builtin named severities
+const (
+	AuditLevel    = "audit"
+	SecurityLevel = "security"
+)
+
+var (
+	levelsMtx sync.RWMutex
+	// levels maps named severities to the verbosity they are logged at
+	levels = map[string]int{
+		AuditLevel:    0,
+		SecurityLevel: 0,
+	}
+)
+
+// levelNames maps the conventional level names accepted by ParseLevel to the
+// verbosity they enable. Errors, warnings and informational entries are all
+// logged at verbosity 0
+var levelNames = map[string]int{
+	"error": 0,
+	"warn":  0,
+	"info":  0,
+	"debug": 1,
+}
+
+// ParseLevel returns the verbosity for SetLogLevel of s, which is one of
+// debug, info, warn or error in any case or a non-negative integer. An error
+// wrapping ErrInvalidOption is returned for any other value
+func ParseLevel(s string) (int, error) {
+	s = strings.TrimSpace(s)
+	if v, ok := levelNames[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return 0, kverrors.Add(ErrInvalidOption,
+			"option", "level",
+			"value", s,
+			"reason", "must be one of debug, info, warn, error or a non-negative integer",
+		)
+	}
+	return v, nil
+}
+
+// RegisterLevel registers a named severity that is logged at verbosity v.
+// Entries logged with Severity(name) have name as their level instead of the
+// verbosity, in lowercase unless changed with WithLevelCase. Registering an
+// existing name replaces its verbosity.
+func RegisterLevel(name string, v int) {
+	levelsMtx.Lock()
+	defer levelsMtx.Unlock()
+	levels[name] = v
+}
+
+// levelVerbosity returns the verbosity name is logged at. Unregistered names
+// are logged at verbosity 0.
+func levelVerbosity(name string) int {
+	levelsMtx.RLock()
+	defer levelsMtx.RUnlock()
+	return levels[name]
+}
+
+// Severity returns a logger whose entries have the named severity name as
+// their level. Their verbosity is that of name plus the verbosity l was
+// derived with by V, so Severity(name).V(1) and V(1).Severity(name) are the
+// same. See RegisterLevel
+func (l *Logger) Severity(name string) logr.Logger {
+	offset := l.verbosity
+	if l.severity != "" {
+		offset -= Verbosity(levelVerbosity(l.severity))
+	}
+	ll := l.clone()
+	ll.verbosity = offset + Verbosity(levelVerbosity(name))
+	ll.severity = name
+	return ll
+}
+
+// level returns the value of the level field of l's entries
+func (l *Logger) level() string {
+	if l.severity == "" {
+		return l.verbosity.String()
+	}
+	if l.opts.upperLevels {
+		return strings.ToUpper(l.severity)
+	}
+	return strings.ToLower(l.severity)
+}
+
+// Severity returns a logger for the named severity name if the root logger
+// is *log.Logger, otherwise it returns the root logger at the verbosity of
+// name. See RegisterLevel
+func Severity(name string) logr.Logger {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	if ll, ok := root().(*Logger); ok {
+		return ll.Severity(name)
+	}
+	return root().V(levelVerbosity(name))
+}
+
+// Audit logs a message to the audit logger. See AuditLogger
+func Audit(msg string, keysAndValues ...interface{}) {
+	AuditLogger().Info(msg, keysAndValues...)
+}
+
+// Security logs a message with the SecurityLevel severity
+func Security(msg string, keysAndValues ...interface{}) {
+	Severity(SecurityLevel).Info(msg, keysAndValues...)
+}
+
+// AuditLogger returns a logger for audit events. Its entries have the
+// AuditLevel severity, are marked with AuditTypeKey=AuditTypeValue and are
+// never filtered by the log level. They are written to the writer set with
+// WithAuditOutput or, if none is set, to the output of l, with the encoder of
+// l. Fanout targets, the writers and encoders set with WithOutputForLevel and
+// WithEncoderForLevel and the error mirror don't apply to them.
+func (l *Logger) AuditLogger() logr.Logger {
+	ll := l.clone()
+	if l.opts.auditOutput != nil {
+		ll.output = l.opts.auditOutput
+	}
+	ll.verbosity = Verbosity(levelVerbosity(AuditLevel))
+	ll.severity = AuditLevel
+	ll.opts.fanout = nil
+	ll.opts.levelOutputs = nil
+	ll.opts.levelEncoders = nil
+	ll.opts.errorMirror = nil
+	ll.opts.unfiltered = true
+	ll.opts.fixedFields = mergeFields(l.opts.fixedFields, AuditTypeKey, AuditTypeValue)
+	return ll
+}
+
+// AuditLogger returns the audit logger of the root logger if it is
+// *log.Logger, otherwise it returns the root logger with the AuditLevel
+// severity and audit marker. Configure its output with WithAuditOutput.
+func AuditLogger() logr.Logger {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	if ll, ok := root().(*Logger); ok {
+		return ll.AuditLogger()
+	}
+	return root().V(levelVerbosity(AuditLevel)).WithValues(AuditTypeKey, AuditTypeValue)
+}
+
+// EffectiveLevel returns the verbosity l logs at, including all offsets added
+// with V, if l is a *log.Logger. Otherwise it returns false.
+func EffectiveLevel(l logr.Logger) (int, bool) {
+	ll, ok := l.(*Logger)
+	if !ok {
+		return 0, false
+	}
+	ll.mtx.RLock()
+	defer ll.mtx.RUnlock()
+	return int(ll.verbosity), true
+}
@@ -0,0 +1,176 @@
+package log_test
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/ViaQ/logerr/kverrors"
+	"github.com/ViaQ/logerr/log"
+	"github.com/go-logr/logr"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSeverity_RendersLevelName(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	log.UseLogger(log.NewLogger("", buf, 0, log.JSONEncoder{}))
+
+	log.Audit("user logged in")
+	require.Equal(t, log.AuditLevel, decodeEntry(t, buf.Bytes())[log.LevelKey])
+
+	buf.Reset()
+	log.Security("permission denied")
+	require.Equal(t, log.SecurityLevel, decodeEntry(t, buf.Bytes())[log.LevelKey])
+}
+
+func TestRegisterLevel(t *testing.T) {
+	t.Cleanup(log.SnapshotLevels())
+	log.SetLogLevel(0)
+	log.RegisterLevel("billing", 0)
+	log.RegisterLevel("billing-debug", 2)
+
+	buf := bytes.NewBuffer(nil)
+	log.UseLogger(log.NewLogger("", buf, 0, log.JSONEncoder{}))
+
+	log.Severity("billing").Info("invoice sent")
+	require.Equal(t, "billing", decodeEntry(t, buf.Bytes())[log.LevelKey])
+
+	buf.Reset()
+	log.Severity("billing-debug").Info("invoice details")
+	require.Zero(t, buf.Len(), "expected severity to honor its verbosity")
+}
+
+func TestSeverity_PreservesVerbosityOffset(t *testing.T) {
+	t.Cleanup(log.SnapshotLevels())
+	log.RegisterLevel("billing", 1)
+	log.RegisterLevel("billing-debug", 3)
+
+	logger := log.NewLogger("", bytes.NewBuffer(nil), 0, log.JSONEncoder{})
+	for _, tc := range []struct {
+		logger logr.Logger
+		want   int
+	}{
+		{logger.Severity("billing"), 1},
+		{logger.V(2).(*log.Logger).Severity("billing"), 3},
+		{logger.Severity("billing").V(2), 3},
+		{logger.V(2).(*log.Logger).Severity("billing").(*log.Logger).Severity("billing-debug"), 5},
+	} {
+		level, ok := log.EffectiveLevel(tc.logger)
+		require.True(t, ok)
+		require.Equal(t, tc.want, level)
+	}
+}
+
+func TestAuditLogger_IgnoresLogLevel(t *testing.T) {
+	t.Cleanup(log.SnapshotLevels())
+	log.RegisterLevel(log.AuditLevel, 3)
+	log.SetLogLevel(0)
+
+	buf, audit := bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithAuditOutput(audit),
+		log.WithFixedFields(log.AuditTypeKey, "overridden"),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.AuditLogger().V(1).Info("user deleted", "user", "alice")
+	log.Audit("user logged in")
+	log.V(1).Info("hidden")
+
+	require.Empty(t, buf.String())
+
+	lines := bytes.Split(bytes.TrimSpace(audit.Bytes()), []byte("\n"))
+	require.Len(t, lines, 2)
+	for _, line := range lines {
+		entry := decodeEntry(t, line)
+		require.Equal(t, log.AuditTypeValue, entry[log.AuditTypeKey])
+		require.Equal(t, log.AuditLevel, entry[log.LevelKey])
+	}
+	require.Equal(t, "alice", decodeEntry(t, lines[0])["user"])
+}
+
+func TestAuditLogger_IgnoresLevelOutputsAndEncoders(t *testing.T) {
+	buf, levelBuf, audit := bytes.NewBuffer(nil), bytes.NewBuffer(nil), bytes.NewBuffer(nil)
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithAuditOutput(audit),
+		log.WithOutputForLevel(map[int]io.Writer{0: levelBuf}),
+		log.WithEncoderForLevel(map[int]log.Encoder{0: log.LogfmtEncoder{}}),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Audit("user logged in")
+
+	require.Empty(t, buf.String())
+	require.Empty(t, levelBuf.String())
+	require.Equal(t, log.AuditTypeValue, decodeEntry(t, audit.Bytes())[log.AuditTypeKey])
+}
+
+func TestAuditLogger_DefaultsToOutput(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	log.UseLogger(log.NewLogger("", buf, 0, log.JSONEncoder{}))
+
+	log.Audit("user logged in")
+	require.Equal(t, log.AuditTypeValue, decodeEntry(t, buf.Bytes())[log.AuditTypeKey])
+}
+
+func TestEffectiveLevel(t *testing.T) {
+	logger := log.NewLogger("", bytes.NewBuffer(nil), 1, log.JSONEncoder{})
+
+	level, ok := log.EffectiveLevel(logger.V(2).WithName("child").V(1))
+	require.True(t, ok)
+	require.Equal(t, 4, level)
+
+	_, ok = log.EffectiveLevel(nopLogger{})
+	require.False(t, ok)
+}
+
+func TestParseLevel(t *testing.T) {
+	for s, want := range map[string]int{
+		"debug": 1,
+		"info":  0,
+		"warn":  0,
+		"error": 0,
+		"DEBUG": 1,
+		"Info":  0,
+		" 3 ":   3,
+		"0":     0,
+		"10":    10,
+	} {
+		got, err := log.ParseLevel(s)
+		require.NoError(t, err, s)
+		require.Equal(t, want, got, s)
+	}
+}
+
+func TestParseLevel_Invalid(t *testing.T) {
+	for _, s := range []string{"", "verbose", "-1", "1.5", "warning"} {
+		_, err := log.ParseLevel(s)
+		require.Error(t, err, s)
+		require.Equal(t, log.ErrInvalidOption, kverrors.Root(err))
+		require.Equal(t, s, kverrors.KVs(err)["value"])
+	}
+}
+
+func TestWithLevelCase(t *testing.T) {
+	t.Cleanup(log.SnapshotLevels())
+	log.RegisterLevel("Billing", 0)
+
+	for upper, want := range map[bool][]string{
+		false: {"audit", "billing", "0"},
+		true:  {"AUDIT", "BILLING", "0"},
+	} {
+		buf := bytes.NewBuffer(nil)
+		logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+		log.WithLevelCase(upper)(logger)
+
+		var got []string
+		for _, l := range []logr.Logger{logger.Severity(log.AuditLevel), logger.Severity("Billing"), logger} {
+			buf.Reset()
+			l.Info("hello")
+			got = append(got, decodeEntry(t, buf.Bytes())[log.LevelKey].(string))
+		}
+		require.Equal(t, want, got, "upper=%t", upper)
+	}
+}
@@ -5,6 +5,7 @@ import (
 	"io"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/ViaQ/logerr/kverrors"
 	"github.com/go-logr/logr"
@@ -14,17 +15,34 @@ import (
 // that is incompatible with logr.Logger interface
 var ErrUnknownLoggerType = kverrors.New("unknown error type")
 
+// ErrInvalidOption is returned when the options used to initialize the logger
+// are invalid or conflict with each other
+var ErrInvalidOption = kverrors.New("invalid logger option")
+
 var (
-	defaultOutput  io.Writer = os.Stdout
-	defautLogLevel           = 0
+	defautLogLevel = 0
 
 	// logLevel sets the level at which you want logs to be displayed
 	// By default the verbosity is set to 0 and all logs that do not
 	// use V(...) will be printed. To increase logging verbosity
 	logLevel = defautLogLevel
 
-	mtx    sync.RWMutex
-	logger logr.Logger = NewLogger("", os.Stdout, 0, JSONEncoder{})
+	mtx sync.RWMutex
+	// logger is the root logger set by Init or UseLogger. Use root to get
+	// the root logger, which falls back to defaultLogger
+	logger logr.Logger
+
+	// defaultLogger is created on first use so that it writes to the
+	// os.Stdout of that time rather than the one at package initialization
+	defaultLogger     logr.Logger
+	defaultLoggerOnce sync.Once
+
+	// levelChangeFuncs are called by SetLogLevel. See OnLevelChange
+	levelChangeFuncs []func(old, new int)
+
+	// exitFunc is called by all paths that terminate the process so that
+	// they can be intercepted in tests
+	exitFunc = os.Exit
 )
 
 // Init initializes the logger. This is required to use logging correctly
@@ -38,32 +56,84 @@ func Init(component string, keyValuePairs ...interface{}) {
 
 // MustInit calls Init and panics if it returns an error
 func MustInit(component string, keyValuePairs ...interface{}) {
-	Init(component, keyValuePairs...)
+	MustInitWithOptions(component, nil, keyValuePairs...)
 }
 
-// InitWithOptions inits the logger with the provided opts
+// InitWithOptions inits the logger with the provided opts. If opts are
+// invalid the logger is left unchanged. Use InitE to retrieve the error.
 func InitWithOptions(component string, opts []Option, keyValuePairs ...interface{}) {
+	_ = InitE(component, opts, keyValuePairs...)
+}
+
+// InitE inits the logger with the provided opts and returns an error wrapping
+// ErrInvalidOption if opts are invalid, in which case the logger is left
+// unchanged.
+func InitE(component string, opts []Option, keyValuePairs ...interface{}) error {
+	ll, err := initLogger(component, opts, keyValuePairs...)
+	if err != nil {
+		return err
+	}
+	if ll.opts.logLevel != nil {
+		SetLogLevel(*ll.opts.logLevel)
+	}
+	return nil
+}
+
+// initLogger creates a logger with opts and uses it if opts are valid
+func initLogger(component string, opts []Option, keyValuePairs ...interface{}) (*Logger, error) {
 	mtx.Lock()
 	defer mtx.Unlock()
 
-	ll := NewLogger(component, defaultOutput, 0, JSONEncoder{}, keyValuePairs...)
+	ll := NewLogger(component, os.Stdout, 0, JSONEncoder{}, keyValuePairs...)
 
 	for _, opt := range opts {
 		opt(ll)
 	}
 
+	if err := ll.validate(); err != nil {
+		_ = ll.Close()
+		return nil, err
+	}
+
+	// the previous root is replaced so flush its batch and stop its flusher
+	if prev, ok := logger.(*Logger); ok {
+		prev.mtx.RLock()
+		bw, batched := prev.output.(*batchWriter)
+		prev.mtx.RUnlock()
+		if batched {
+			_ = bw.Close()
+		}
+	}
+
 	// don't lock because we already have a lock
 	useLogger(ll)
+	return ll, nil
 }
 
-// MustInitWithOptions calls InitWithOptions and panics if an error is returned
+// MustInitWithOptions calls InitE and panics if an error is returned
 func MustInitWithOptions(component string, opts []Option, keyValuePairs ...interface{}) {
-	InitWithOptions(component, opts, keyValuePairs...)
+	if err := InitE(component, opts, keyValuePairs...); err != nil {
+		panic(err)
+	}
 }
 
 // GetLogger returns the root logger used for logging
 func GetLogger() logr.Logger {
-	return logger
+	mtx.RLock()
+	defer mtx.RUnlock()
+	return root()
+}
+
+// root returns the root logger or, if neither Init nor UseLogger were called,
+// the default logger writing to os.Stdout. mtx must be held.
+func root() logr.Logger {
+	if logger != nil {
+		return logger
+	}
+	defaultLoggerOnce.Do(func() {
+		defaultLogger = NewLogger("", os.Stdout, 0, JSONEncoder{})
+	})
+	return defaultLogger
 }
 
 // UseLogger bypasses the requirement for Init and sets the logger to l
@@ -88,7 +158,7 @@ func useLogger(l logr.Logger) {
 func Info(msg string, keysAndValues ...interface{}) {
 	mtx.RLock()
 	defer mtx.RUnlock()
-	logger.Info(msg, keysAndValues...)
+	root().Info(msg, keysAndValues...)
 }
 
 // Error logs an error, with the given message and key/value pairs as context.
@@ -102,7 +172,72 @@ func Info(msg string, keysAndValues ...interface{}) {
 func Error(err error, msg string, keysAndValues ...interface{}) {
 	mtx.RLock()
 	defer mtx.RUnlock()
-	logger.Error(err, msg, keysAndValues...)
+	root().Error(err, msg, keysAndValues...)
+}
+
+// InfoAt logs a non-error message like Info but with t as the time of the
+// entry instead of the current time, e.g. to backfill historical events. If
+// the root logger is not *log.Logger, t is ignored.
+func InfoAt(t time.Time, msg string, keysAndValues ...interface{}) {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	if ll, ok := root().(*Logger); ok {
+		ll.InfoAt(t, msg, keysAndValues...)
+		return
+	}
+	root().Info(msg, keysAndValues...)
+}
+
+// InfoIf logs a message like Info if cond is true. Note that the arguments
+// are still evaluated when cond is false because Go evaluates arguments
+// eagerly, so expensive values should be guarded with an if statement.
+func InfoIf(cond bool, msg string, keysAndValues ...interface{}) {
+	if !cond {
+		return
+	}
+	mtx.RLock()
+	defer mtx.RUnlock()
+	root().Info(msg, keysAndValues...)
+}
+
+// ErrorIf logs an error like Error if cond is true. Note that the arguments
+// are still evaluated when cond is false because Go evaluates arguments
+// eagerly, so expensive values should be guarded with an if statement.
+func ErrorIf(cond bool, err error, msg string, keysAndValues ...interface{}) {
+	if !cond {
+		return
+	}
+	mtx.RLock()
+	defer mtx.RUnlock()
+	root().Error(err, msg, keysAndValues...)
+}
+
+// ErrorReturn logs err like Error and returns it so that an error can be
+// logged and returned in one statement:
+//
+//	return log.ErrorReturn(err, "failed to save")
+//
+// If err is nil nothing is logged and nil is returned.
+func ErrorReturn(err error, msg string, keysAndValues ...interface{}) error {
+	if err == nil {
+		return nil
+	}
+	mtx.RLock()
+	defer mtx.RUnlock()
+	root().Error(err, msg, keysAndValues...)
+	return err
+}
+
+// Fatal logs an error like Error, flushes any batched output and exits the
+// process with status code 1
+func Fatal(err error, msg string, keysAndValues ...interface{}) {
+	mtx.RLock()
+	root().Error(err, msg, keysAndValues...)
+	if ll, err := sink(); err == nil {
+		_ = ll.Close()
+	}
+	mtx.RUnlock()
+	exitFunc(1)
 }
 
 // WithValues adds some key-value pairs of context to a logger.
@@ -110,14 +245,76 @@ func Error(err error, msg string, keysAndValues ...interface{}) {
 func WithValues(keysAndValues ...interface{}) logr.Logger {
 	mtx.RLock()
 	defer mtx.RUnlock()
-	return logger.WithValues(keysAndValues...)
+	return root().WithValues(keysAndValues...)
 }
 
-// SetLogLevel sets the output verbosity
+// WithValuesMap adds the entries of m as context to a logger in sorted key
+// order. See WithValues for more information.
+func WithValuesMap(m map[string]interface{}) logr.Logger {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	return root().WithValues(sortedKeysAndValues(m)...)
+}
+
+// SetLogLevel sets the output verbosity. The functions registered with
+// OnLevelChange are called if the verbosity changed
 func SetLogLevel(v int) {
 	mtx.Lock()
-	defer mtx.Unlock()
+	old := logLevel
 	logLevel = v
+	funcs := levelChangeFuncs
+	mtx.Unlock()
+
+	if old == v {
+		return
+	}
+	for _, fn := range funcs {
+		fn(old, v)
+	}
+}
+
+// WithLevel sets the output verbosity to v while fn runs and restores the
+// previous verbosity afterwards, even if fn panics. The verbosity is global,
+// so it applies to all goroutines for the duration of fn, and concurrent
+// calls restore the verbosity in the order they return rather than the order
+// they were called.
+func WithLevel(v int, fn func()) {
+	mtx.RLock()
+	prev := logLevel
+	mtx.RUnlock()
+
+	SetLogLevel(v)
+	defer SetLogLevel(prev)
+	fn()
+}
+
+// OnLevelChange registers fn to be called with the old and new verbosity
+// after SetLogLevel changed it. Functions are called in the order they were
+// registered, from the goroutine that called SetLogLevel.
+func OnLevelChange(fn func(old, new int)) {
+	mtx.Lock()
+	defer mtx.Unlock()
+	levelChangeFuncs = append(append([]func(old, new int){}, levelChangeFuncs...), fn)
+}
+
+// Sink returns the root logger if it is *log.Logger so that it can be
+// configured after Init, otherwise it returns ErrUnknownLoggerType
+func Sink() (*Logger, error) {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	return sink()
+}
+
+// sink returns the root logger if it is *log.Logger. mtx must be held.
+func sink() (*Logger, error) {
+	ll, ok := root().(*Logger)
+	if !ok {
+		return nil, kverrors.Add(ErrUnknownLoggerType,
+			"logger_type", fmt.Sprintf("%T", root()),
+			"expected_type", fmt.Sprintf("%T", &Logger{}),
+		)
+	}
+	return ll, nil
 }
 
 // SetOutput sets the logger output to w if the root logger is *log.Logger
@@ -125,18 +322,87 @@ func SetLogLevel(v int) {
 func SetOutput(w io.Writer) error {
 	mtx.RLock()
 	defer mtx.RUnlock()
-	switch ll := logger.(type) {
-	case *Logger:
-		ll.SetOutput(w)
-	default:
-		return kverrors.Add(ErrUnknownLoggerType,
-			"logger_type", fmt.Sprintf("%T", logger),
-			"expected_type", fmt.Sprintf("%T", &Logger{}),
-		)
+	ll, err := sink()
+	if err != nil {
+		return err
+	}
+	ll.SetOutput(w)
+	return nil
+}
+
+// captureMtx serializes CaptureOutput
+var captureMtx sync.Mutex
+
+// CaptureOutput calls fn with the output of the root logger redirected to a
+// buffer and returns what was written to it, e.g. to assert on entries in
+// tests. The original output is restored when fn returns or panics, after any
+// batched entries were flushed to the buffer. Captures are serialized. Loggers
+// derived from the root logger during the call are captured. Loggers derived
+// before the call are only captured if the output is batched, see
+// WithFlushInterval and WithFlushBytes, because they share the batch with the
+// root logger; otherwise they keep writing to the original output. It returns
+// ErrUnknownLoggerType if the root logger is not *log.Logger.
+func CaptureOutput(fn func()) ([]byte, error) {
+	captureMtx.Lock()
+	defer captureMtx.Unlock()
+
+	ll, err := Sink()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &lockedBuffer{}
+	prev := ll.swapOutput(buf)
+	func() {
+		// restoring flushes batched entries to buf
+		defer ll.swapOutput(prev)
+		fn()
+	}()
+	return buf.Bytes(), nil
+}
+
+// SetEncoder replaces the encoder of the root logger with e if it is
+// *log.Logger otherwise it returns ErrUnknownLoggerType. This allows switching
+// formats after Init, e.g. to the ConsoleEncoder when a flag asks for pretty
+// output. Options configuring the JSONEncoder that were applied before are
+// not carried over.
+func SetEncoder(e Encoder) error {
+	if e == nil {
+		return kverrors.Add(ErrInvalidOption, "option", "encoder", "reason", "must not be nil")
+	}
+
+	mtx.RLock()
+	defer mtx.RUnlock()
+	ll, err := sink()
+	if err != nil {
+		return err
 	}
+	ll.SetEncoder(e)
 	return nil
 }
 
+// Pending returns the number of entries of the root logger that were not
+// written yet if it is *log.Logger, otherwise 0. See Logger.Pending
+func Pending() int {
+	ll, err := Sink()
+	if err != nil {
+		return 0
+	}
+	return ll.Pending()
+}
+
+// Close flushes and closes the root logger if it is *log.Logger
+// otherwise it returns ErrUnknownLoggerType
+func Close() error {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	ll, err := sink()
+	if err != nil {
+		return err
+	}
+	return ll.Close()
+}
+
 // WithName adds a new element to the logger's name.
 // Successive calls with WithName continue to append
 // suffixes to the logger's name.  It's strongly recommended
@@ -145,7 +411,7 @@ func SetOutput(w io.Writer) error {
 func WithName(name string) logr.Logger {
 	mtx.RLock()
 	defer mtx.RUnlock()
-	return logger.WithName(name)
+	return root().WithName(name)
 }
 
 // V returns an Logger value for a specific verbosity level, relative to
@@ -155,5 +421,5 @@ func WithName(name string) logr.Logger {
 func V(level int) logr.Logger {
 	mtx.RLock()
 	defer mtx.RUnlock()
-	return logger.V(level)
+	return root().V(level)
 }
@@ -2,12 +2,16 @@ package log_test
 
 import (
 	"bytes"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/ViaQ/logerr/internal/kv"
 
 	"io/ioutil"
+	"os"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/ViaQ/logerr/kverrors"
 	"github.com/ViaQ/logerr/log"
@@ -155,6 +159,33 @@ func TestSetOutput_WithUnknownLogger_Errors(t *testing.T) {
 	require.Equal(t, log.ErrUnknownLoggerType, actual)
 }
 
+func TestSetEncoder_SwitchesFormat(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf)})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Info("as json")
+	require.True(t, json.Valid(buf.Bytes()), buf.String())
+
+	buf.Reset()
+	require.NoError(t, log.SetEncoder(log.ConsoleEncoder{}))
+	log.Info("as text", "user", "alice")
+	require.False(t, json.Valid(buf.Bytes()), buf.String())
+	require.Contains(t, buf.String(), "\tas text\tuser=alice\n")
+}
+
+func TestSetEncoder_WithUnknownLogger_Errors(t *testing.T) {
+	log.UseLogger(nopLogger{})
+
+	err := log.SetEncoder(log.ConsoleEncoder{})
+	require.Equal(t, log.ErrUnknownLoggerType, kverrors.Root(err))
+}
+
+func TestSetEncoder_Nil_Errors(t *testing.T) {
+	err := log.SetEncoder(nil)
+	require.Equal(t, log.ErrInvalidOption, kverrors.Root(err))
+}
+
 func TestWithName(t *testing.T) {
 	obs, _ := NewObservedLogger()
 
@@ -188,3 +219,305 @@ func TestV(t *testing.T) {
 	require.NotEmpty(t, logs)
 	require.Equal(t, msg, logs[0].Message)
 }
+
+func TestFatal_LogsAndExits(t *testing.T) {
+	var code int
+	restore := log.SetExitFunc(func(c int) {
+		code = c
+	})
+	defer restore()
+
+	buf := bytes.NewBuffer(nil)
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithFlushBytes(1 << 20),
+	})
+
+	log.Fatal(errors.New("fail boat"), t.Name())
+
+	require.Equal(t, 1, code)
+	require.Contains(t, buf.String(), "fail boat", "expected batched output to be flushed")
+}
+
+func TestInitE_InvalidOptions(t *testing.T) {
+	for name, opts := range map[string][]log.Option{
+		"nil output":            {log.WithOutput(nil)},
+		"negative flush bytes":  {log.WithFlushBytes(-1)},
+		"negative flush period": {log.WithFlushInterval(-1)},
+		"negative max elements": {log.WithMaxArrayElements(-1)},
+		"bad float notation":    {log.WithFloatNotation('x')},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, logger := NewObservedLogger()
+			log.UseLogger(logger)
+
+			err := log.InitE(t.Name(), opts)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, log.ErrInvalidOption), "expected %v to be %v", err, log.ErrInvalidOption)
+			require.Equal(t, logger, log.GetLogger(), "expected logger to be unchanged")
+
+			require.Panics(t, func() {
+				log.MustInitWithOptions(t.Name(), opts)
+			})
+		})
+	}
+}
+
+func TestInitE_ValidOptions(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	require.NoError(t, log.InitE(t.Name(), []log.Option{log.WithOutput(buf)}))
+
+	log.Info("hello, world")
+	require.Contains(t, buf.String(), "hello, world")
+}
+
+func TestWithValuesMap(t *testing.T) {
+	fields := map[string]interface{}{
+		"zone":    "eu-1",
+		"cluster": "prod",
+		"replica": 3,
+	}
+
+	obs, logger := NewObservedLogger()
+	log.UseLogger(logger)
+
+	log.WithValuesMap(fields).Info(t.Name())
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 1)
+	assert.EqualValues(t, fields, logs[0].Context)
+
+	buf := bytes.NewBuffer(nil)
+	log.UseLogger(log.NewLogger("", buf, 0, log.JSONEncoder{}))
+
+	log.WithValuesMap(fields).Info(t.Name())
+	expected := buf.String()
+	for i := 0; i < 5; i++ {
+		buf.Reset()
+		log.WithValuesMap(fields).Info(t.Name())
+		require.Equal(t, expected, buf.String(), "expected stable output")
+	}
+}
+
+func TestDefaultLogger_UsesStdoutAtFirstUse(t *testing.T) {
+	f, err := ioutil.TempFile(t.TempDir(), "stdout")
+	require.NoError(t, err)
+	defer f.Close()
+
+	stdout := os.Stdout
+	os.Stdout = f
+	defer func() {
+		os.Stdout = stdout
+		log.ResetDefaultLogger()
+	}()
+
+	log.ResetDefaultLogger()
+	log.Info(t.Name())
+
+	b, err := ioutil.ReadFile(f.Name())
+	require.NoError(t, err)
+	require.Contains(t, string(b), t.Name())
+}
+
+func TestInfoIf_ErrorIf(t *testing.T) {
+	obs, logger := NewObservedLogger()
+	log.UseLogger(logger)
+
+	log.InfoIf(false, t.Name())
+	log.ErrorIf(false, errors.New("fail boat"), t.Name())
+	require.Empty(t, obs.TakeAll())
+
+	log.InfoIf(true, t.Name())
+	log.ErrorIf(true, errors.New("fail boat"), t.Name())
+	logs := obs.TakeAll()
+	require.Len(t, logs, 2)
+	require.Nil(t, logs[0].Error)
+	require.NotNil(t, logs[1].Error)
+}
+
+func TestInfoAt_UsesInjectedTime(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	log.UseLogger(log.NewLogger("", buf, 0, log.JSONEncoder{}))
+
+	at := time.Date(2019, time.March, 4, 5, 6, 7, 8, time.FixedZone("test", 3600))
+	log.InfoAt(at, t.Name())
+
+	m := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &m))
+	require.Equal(t, "2019-03-04T04:06:07.000000008Z", m[log.TimeStampKey])
+}
+
+func TestSink(t *testing.T) {
+	log.Init(t.Name())
+
+	ll, err := log.Sink()
+	require.NoError(t, err)
+	require.Same(t, log.GetLogger(), ll)
+}
+
+func TestSink_WithUnknownLogger_Errors(t *testing.T) {
+	log.UseLogger(nopLogger{})
+
+	ll, err := log.Sink()
+	require.Nil(t, ll)
+	require.Equal(t, log.ErrUnknownLoggerType, kverrors.Root(err))
+}
+
+func TestOnLevelChange(t *testing.T) {
+	defer log.ResetLevelChangeFuncs()
+	log.SetLogLevel(0)
+
+	type change struct{ old, new int }
+	var first, second []change
+	log.OnLevelChange(func(old, new int) { first = append(first, change{old, new}) })
+	log.OnLevelChange(func(old, new int) { second = append(second, change{old, new}) })
+
+	log.SetLogLevel(2)
+	log.SetLogLevel(2)
+	log.SetLogLevel(1)
+	log.SetLogLevel(0)
+
+	expected := []change{{0, 2}, {2, 1}, {1, 0}}
+	require.Equal(t, expected, first)
+	require.Equal(t, expected, second)
+}
+
+func TestInitE_WithLogLevel(t *testing.T) {
+	defer log.ResetLevelChangeFuncs()
+	defer log.SetLogLevel(0)
+	log.SetLogLevel(0)
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(ioutil.Discard)})
+	defer log.MustInit("")
+
+	var changes [][2]int
+	log.OnLevelChange(func(old, new int) { changes = append(changes, [2]int{old, new}) })
+
+	err := log.InitE(t.Name(), []log.Option{log.WithLogLevel(3), log.WithMaxFields(-1)})
+	require.Error(t, err)
+	require.Empty(t, changes)
+	require.False(t, log.V(3).Enabled())
+
+	require.NoError(t, log.InitE(t.Name(), []log.Option{log.WithOutput(ioutil.Discard), log.WithLogLevel(3)}))
+	require.Equal(t, [][2]int{{0, 3}}, changes)
+	require.True(t, log.V(3).Enabled())
+}
+
+func TestWithName_AppendsToComponent(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	log.MustInitWithOptions("app", []log.Option{log.WithOutput(buf)})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.WithName("controller").WithName("sync").Info("hello")
+	require.Equal(t, "app_controller_sync", decodeEntry(t, buf.Bytes())[log.ComponentKey])
+
+	buf.Reset()
+	log.WithName("").Info("hello")
+	require.Equal(t, "app", decodeEntry(t, buf.Bytes())[log.ComponentKey])
+}
+
+func TestWithLevel_RestoresLevel(t *testing.T) {
+	log.SetLogLevel(1)
+	defer log.SetLogLevel(0)
+
+	log.WithLevel(3, func() {
+		require.True(t, log.V(3).Enabled())
+	})
+	require.False(t, log.V(3).Enabled())
+	require.True(t, log.V(1).Enabled())
+
+	require.Panics(t, func() {
+		log.WithLevel(3, func() { panic("boom") })
+	})
+	require.False(t, log.V(3).Enabled())
+	require.True(t, log.V(1).Enabled())
+}
+
+func TestErrorReturn(t *testing.T) {
+	obs, logger := NewObservedLogger()
+	log.UseLogger(logger)
+
+	err := errors.New("fail boat")
+	require.Same(t, err, log.ErrorReturn(err, "failed to save", "id", 1))
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 1)
+	require.Equal(t, "failed to save", logs[0].Message)
+	require.Equal(t, 1, logs[0].Context["id"])
+	require.EqualError(t, logs[0].Error, "fail boat")
+
+	require.NoError(t, log.ErrorReturn(nil, "failed to save"))
+	require.Empty(t, obs.TakeAll())
+}
+
+func TestCaptureOutput(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithFlushInterval(time.Hour),
+		log.WithFlushBytes(1 << 20),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	before := log.WithName("before")
+	out, err := log.CaptureOutput(func() {
+		log.Info("first")
+		log.WithName("child").Info("second")
+		before.Info("third")
+	})
+	require.NoError(t, err)
+
+	lines := strings.Split(strings.TrimSpace(string(out)), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, "first", decodeEntry(t, []byte(lines[0]))[log.MessageKey])
+	require.Equal(t, "second", decodeEntry(t, []byte(lines[1]))[log.MessageKey])
+	require.Equal(t, "third", decodeEntry(t, []byte(lines[2]))[log.MessageKey])
+
+	log.Info("after")
+	ll, err := log.Sink()
+	require.NoError(t, err)
+	require.NoError(t, ll.Flush())
+	require.NotContains(t, buf.String(), "first")
+	require.Contains(t, buf.String(), "after")
+}
+
+func TestCaptureOutput_RestoresOnPanic(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf)})
+	defer log.MustInit("")
+
+	require.Panics(t, func() {
+		_, _ = log.CaptureOutput(func() {
+			log.Info("captured")
+			panic("fail boat")
+		})
+	})
+
+	log.Info("after")
+	require.NotContains(t, buf.String(), "captured")
+	require.Contains(t, buf.String(), "after")
+}
+
+func TestCaptureOutput_UnbatchedDerivedLogger(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf)})
+	defer log.MustInit("")
+
+	before := log.WithName("before")
+	out, err := log.CaptureOutput(func() {
+		log.Info("captured")
+		before.Info("not captured")
+	})
+	require.NoError(t, err)
+
+	require.Contains(t, string(out), "captured")
+	require.NotContains(t, string(out), "not captured")
+	require.Contains(t, buf.String(), "not captured")
+}
+
+func TestCaptureOutput_UnknownLogger(t *testing.T) {
+	log.UseLogger(nopLogger{})
+	defer log.MustInit("")
+
+	_, err := log.CaptureOutput(func() {})
+	require.Equal(t, log.ErrUnknownLoggerType, kverrors.Root(err))
+}
@@ -1,15 +1,19 @@
 package log
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"reflect"
 	"runtime"
 	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/ViaQ/logerr/internal/kv"
@@ -19,14 +23,53 @@ import (
 
 // Keys used to log specific builtin fields
 const (
-	TimeStampKey = "_ts"
-	FileLineKey  = "_file:line"
-	LevelKey     = "_level"
-	ComponentKey = "_component"
-	MessageKey   = "_message"
-	ErrorKey     = "_error"
+	TimeStampKey  = "_ts"
+	FileLineKey   = "_file:line"
+	LevelKey      = "_level"
+	ComponentKey  = "_component"
+	MessageKey    = "_message"
+	ErrorKey      = "_error"
+	StackTraceKey = "_stacktrace"
+	// ErrorChainKey lists the errors in the chain of the logged error. See
+	// WithErrorChain
+	ErrorChainKey = "_error_chain"
+	SequenceKey   = "_seq"
+	// DateKey and HourKey hold the UTC date and hour of the entry. See
+	// WithTimePartitionFields
+	DateKey = "_date"
+	HourKey = "_hour"
+	// GoroutineIDKey holds the id of the goroutine that logged the entry.
+	// See WithGoroutineID
+	GoroutineIDKey = "_goid"
+	// InvalidJSONKey lists the keys of json.RawMessage values that were
+	// not valid JSON and have been logged as strings instead
+	InvalidJSONKey = "_invalid_json"
+	// PanicKey holds the value recovered from a panic while encoding an
+	// entry. See WithPanicHandler
+	PanicKey = "_panic"
+	// RepeatedKey holds the number of identical entries that were
+	// suppressed. See WithCollapseRepeats
+	RepeatedKey = "_repeated"
+	// FieldsTruncatedKey is set to true if fields were dropped because an
+	// entry had more fields than allowed. See WithMaxFields
+	FieldsTruncatedKey = "_fields_truncated"
+	// RateLimitedKey holds the number of entries dropped by the rate limit
+	// in the summary logged before the next entry. See WithRateLimitSummary
+	RateLimitedKey = "_rate_limited"
+	// ThrottledKey holds the number of calls with the same key that were
+	// suppressed since the last entry. See InfoThrottled
+	ThrottledKey = "_throttled"
+	// EntrySizeKey holds the size of the encoded entry in bytes. See
+	// WithEntrySize
+	EntrySizeKey = "_bytes"
+	// StrictKeysKey lists invalid key/value pairs when strict keys are
+	// enabled. See WithStrictKeys
+	StrictKeysKey = "_logerr"
 )
 
+// NameSeparator joins the component and the names added with WithName
+const NameSeparator = "_"
+
 // Line orders log line fields
 type Line struct {
 	Timestamp string
@@ -59,33 +102,7 @@ type LineJSONDev struct {
 
 // MarshalJSON implements custom marshaling for log line: (1) flattening context (2) support for developer mode
 func (l Line) MarshalJSON() ([]byte, error) {
-	lineTemp := LineJSON(l)
-
-	lineValue, err := json.Marshal(lineTemp)
-	if err != nil {
-		return nil, err
-	}
-	verbosity, errConvert := strconv.Atoi(l.Verbosity)
-	if verbosity > 1 && errConvert == nil {
-		lineTempDev := LineJSONDev(l)
-		lineValue, err = json.Marshal(lineTempDev)
-		if err != nil {
-			return nil, err
-		}
-	}
-	lineValue = lineValue[1 : len(lineValue)-1]
-
-	contextValue, err := json.Marshal(lineTemp.Context)
-	if err != nil {
-		return nil, err
-	}
-	contextValue = contextValue[1 : len(contextValue)-1]
-
-	sep := ""
-	if len(contextValue) > 0 {
-		sep = ","
-	}
-	return []byte(fmt.Sprintf("{%s%s%s}", lineValue, sep, contextValue)), nil
+	return marshalLine(l, lineKeys{}, true)
 }
 
 // Verbosity is a level of verbosity to log between 0 and math.MaxInt32
@@ -104,35 +121,184 @@ func (v Verbosity) MarshalJSON() ([]byte, error) {
 // TimestampFunc returns a string formatted version of the current time.
 // This should probably only be used with tests or if you want to change
 // the default time formatting of the output logs.
-var TimestampFunc = func() string {
-	return time.Now().UTC().Format(time.RFC3339Nano)
+var TimestampFunc = defaultTimestampFunc
+
+// defaultTimestampFunc is the initial TimestampFunc
+func defaultTimestampFunc() string {
+	return formatTimestamp(time.Now())
+}
+
+// now returns the current time and the timestamp of an entry logged at it.
+// If TimestampFunc was replaced, its timestamp is used and the time is parsed
+// from it where possible so fields derived from the time match the timestamp.
+func now() (time.Time, string) {
+	t := time.Now()
+	if reflect.ValueOf(TimestampFunc).Pointer() == reflect.ValueOf(defaultTimestampFunc).Pointer() {
+		return t, formatTimestamp(t)
+	}
+	ts := TimestampFunc()
+	if parsed, err := time.Parse(time.RFC3339Nano, ts); err == nil {
+		t = parsed
+	}
+	return t, ts
+}
+
+// formatTimestamp formats t as the timestamp of an entry
+func formatTimestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
 }
 
 // Logger writes logs to a specified output
 type Logger struct {
 	mtx       sync.RWMutex
 	verbosity Verbosity
+	severity  string
 	output    io.Writer
 	context   map[string]interface{}
 	encoder   Encoder
 	name      string
+	opts      options
+	// labels are kept apart from the context. See WithLabels
+	labels map[string]string
+	// groups is the path of the group key/value pairs are nested under. See
+	// WithGroup
+	groups []string
+
+	// violations are the violations of strict keys found in the key/value
+	// pairs added with WithValues
+	violations []string
+}
+
+// options holds the settings applied by Option. They are copied to every
+// logger derived with V, WithName and WithValues
+type options struct {
+	// reserved is the set of reservedKeys. It is replaced by updateReserved
+	// whenever an option changes any of them
+	reserved map[string]struct{}
+	// logLevel is the verbosity set with WithLogLevel that InitE applies once
+	// the options are valid
+	logLevel *int
+	// seq is the sequence counter shared by all derived loggers if
+	// WithSequenceNumbers is enabled
+	seq *uint64
+	// defaultFields are added to entries that don't set them
+	defaultFields map[string]interface{}
+	// fixedFields are added to all entries and override any other value
+	fixedFields map[string]interface{}
+	// errorKey is the key errors are logged under instead of ErrorKey
+	errorKey string
+	// strictKeys enables validation of key/value pairs
+	strictKeys bool
+	// strictKeysHandler is called with each violation of strict keys
+	strictKeysHandler func(violation string)
+	// fanout are written every entry in addition to the output
+	fanout []Target
+	// panicHandler is called with the value recovered from a panic while
+	// encoding an entry
+	panicHandler func(interface{})
+	// levelNumericKey is the key of the numeric level if set
+	levelNumericKey string
+	// localTimestampKey is the key of the timestamp in local time if set
+	localTimestampKey string
+	// fieldNamespace is the key that user supplied fields are nested under
+	fieldNamespace string
+	// valueFormatters replace field values before they are encoded
+	valueFormatters []ValueFormatter
+	// auditOutput is the writer of the audit logger. See AuditLogger
+	auditOutput io.Writer
+	// unfiltered loggers are enabled regardless of the log level
+	unfiltered bool
+	// flushOnError flushes the batched output after every error entry
+	flushOnError bool
+	// goroutineID adds the id of the logging goroutine to every entry
+	goroutineID bool
+	// maxFields is the maximum number of fields of an entry if positive
+	maxFields int
+	// floatNotation is the strconv.FormatFloat format of floats if set
+	floatNotation byte
+	// timestampPrecision is the resolution entry times are truncated to if
+	// positive
+	timestampPrecision time.Duration
+	// maxArrayElements is the maximum number of elements of slices and
+	// arrays if positive
+	maxArrayElements int
+	// levelEncoders replace the encoder for entries of their level
+	levelEncoders map[int]Encoder
+	// levelOutputs replace the output for entries of their level
+	levelOutputs map[int]io.Writer
+	// runtimeTrace logs every entry to the execution tracer
+	runtimeTrace bool
+	// numbersAsStrings and builtinNumbersAsStrings quote the numbers of user
+	// supplied and builtin fields respectively
+	numbersAsStrings        bool
+	builtinNumbersAsStrings bool
+	// correlation find the correlation id of loggers returned by
+	// FromContext
+	correlation []CorrelationExtractor
+	// timePartitionFields adds the date and hour of every entry
+	timePartitionFields bool
+	// registry holds the dynamic fields and hooks shared by all derived
+	// loggers
+	registry *registry
+	// rateLimiter drops entries above the rate limit if WithRateLimit is
+	// enabled. It is shared by all derived loggers
+	rateLimiter *rateLimiter
+	// rateLimitSummary logs the number of dropped entries before the next
+	// allowed entry
+	rateLimitSummary bool
+	// collapser suppresses repeated entries if WithCollapseRepeats is
+	// enabled. It is shared by all derived loggers
+	collapser *collapser
+	// buffer holds entries until the end of a request. See TraceBuffer
+	buffer *traceBuffer
+	// upperLevels writes named severities in uppercase instead of
+	// lowercase
+	upperLevels bool
+	// replaceFields rewrite or drop every field right before encoding
+	replaceFields []FieldReplacer
+	// allowedKeys are the only fields kept besides builtin fields if set
+	allowedKeys map[string]bool
+	// lineTerminator replaces the newline after each entry if set
+	lineTerminator *string
+	// keepInvalidUTF8 writes invalid UTF-8 as it is instead of replacing it.
+	// See WithSanitizeUTF8
+	keepInvalidUTF8 bool
+	// errorMirror is written error entries in addition to the output. See
+	// WithMirrorErrorsToStderr
+	errorMirror io.Writer
+	// closers are closed by Close after the output was flushed
+	closers []io.Closer
+	// stats counts the entries of all derived loggers
+	stats *counters
+	// traceCtx is the context entries are logged to the execution tracer
+	// with. See FromContext
+	traceCtx context.Context
 }
 
 // NewLogger creates a new logger
 func NewLogger(name string, w io.Writer, v Verbosity, e Encoder, keysAndValues ...interface{}) *Logger {
-	return &Logger{
+	l := &Logger{
 		name:      name,
 		verbosity: v,
 		output:    w,
 		context:   kv.ToMap(keysAndValues...),
 		encoder:   e,
+		opts:      options{stats: &counters{}},
 	}
+	l.updateReserved()
+	return l
 }
 
 // combine creates a new map combining context and keysAndValues.
 func combine(context map[string]interface{}, keysAndValues ...interface{}) map[string]interface{} {
 	nc := make(map[string]interface{}, len(context)+len(keysAndValues)/2)
 	for i := 0; i < len(keysAndValues); i += 2 {
+		if f, ok := keysAndValues[i].(Field); ok {
+			// a field is a key and its value in one
+			nc[f.Key] = f
+			i--
+			continue
+		}
 		if i+1 < len(keysAndValues) {
 			key, ok := keysAndValues[i].(string) // It should be a string.
 			if !ok {                             // But this is not the place to panic
@@ -148,11 +314,34 @@ func combine(context map[string]interface{}, keysAndValues ...interface{}) map[s
 	return nc
 }
 
+// clone returns a copy of l that shares its output and options
+func (l *Logger) clone() *Logger {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+	return &Logger{
+		name:      l.name,
+		verbosity: l.verbosity,
+		severity:  l.severity,
+		output:    l.output,
+		context:   l.context,
+		encoder:   l.encoder,
+		opts:      l.opts,
+		labels:    l.labels,
+		groups:    l.groups,
+
+		violations: l.violations,
+	}
+}
+
 // withValues clones the logger and appends keysAndValues
 // but returns a struct instead of the logr.Logger interface
 func (l *Logger) withValues(keysAndValues ...interface{}) *Logger {
-	ll := NewLogger(l.name, l.output, l.verbosity, l.encoder)
-	ll.context = combine(l.context, keysAndValues...)
+	ll := l.clone()
+	ll.context = nest(l.context, l.groups, keysAndValues)
+	if l.opts.strictKeys {
+		violations := l.checkKeys(keysAndValues)
+		ll.violations = append(append([]string{}, l.violations...), violations...)
+	}
 	return ll
 }
 
@@ -161,11 +350,114 @@ func (l *Logger) WithValues(keysAndValues ...interface{}) logr.Logger {
 	return l.withValues(keysAndValues...)
 }
 
-// SetOutput sets the writer that JSON is written to
+// WithValuesMap clones the logger and appends the entries of m. Keys are
+// added in sorted order so that the result is deterministic
+func (l *Logger) WithValuesMap(m map[string]interface{}) logr.Logger {
+	return l.withValues(sortedKeysAndValues(m)...)
+}
+
+// sortedKeysAndValues converts m to key/value pairs ordered by key
+func sortedKeysAndValues(m map[string]interface{}) []interface{} {
+	keysAndValues := make([]interface{}, 0, len(m)*2)
+	for _, k := range sortedKeys(m) {
+		keysAndValues = append(keysAndValues, k, m[k])
+	}
+	return keysAndValues
+}
+
+// SetOutput sets the writer that JSON is written to. If the logger batches
+// its output, the pending batch is flushed before switching writers.
 func (l *Logger) SetOutput(w io.Writer) {
+	_ = l.swapOutput(w)
+}
+
+// swapOutput sets the output like SetOutput and returns the previous one
+func (l *Logger) swapOutput(w io.Writer) io.Writer {
 	l.mtx.Lock()
 	defer l.mtx.Unlock()
+	if bw, ok := l.output.(*batchWriter); ok {
+		return bw.setWriter(w)
+	}
+	prev := l.output
 	l.output = w
+	return prev
+}
+
+// SetEncoder replaces the encoder of l with e. Loggers derived from l before
+// keep their encoder
+func (l *Logger) SetEncoder(e Encoder) {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	l.encoder = e
+	l.updateReserved()
+}
+
+// batcher returns the batchWriter wrapping the output, creating it if the
+// output is not batched yet
+func (l *Logger) batcher() *batchWriter {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+	bw, ok := l.output.(*batchWriter)
+	if !ok {
+		bw = newBatchWriter(l.output)
+		l.output = bw
+	}
+	return bw
+}
+
+// Flush writes any batched entries to the output. It is a no-op if the
+// output is not batched.
+func (l *Logger) Flush() error {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+	if bw, ok := l.output.(*batchWriter); ok {
+		return bw.Flush()
+	}
+	return nil
+}
+
+// Pending returns the number of entries that were logged but not written to
+// the output yet because they are batched, see WithFlushInterval and
+// WithFlushBytes, or waiting to be pushed by a LokiWriter. Together with
+// Stats it can feed metrics that alert when the output backs up.
+func (l *Logger) Pending() int {
+	l.mtx.RLock()
+	output := l.output
+	l.mtx.RUnlock()
+
+	n := 0
+	if bw, ok := output.(*batchWriter); ok {
+		n += bw.Pending()
+		bw.mtx.Lock()
+		output = bw.w
+		bw.mtx.Unlock()
+	}
+	if lw, ok := output.(*LokiWriter); ok {
+		n += lw.Pending()
+	}
+	return n
+}
+
+// Close stops the background flusher and flushes any partial batch, then
+// closes the writers that need it, like the LokiWriter of WithLoki. It is a
+// no-op if the output is not batched and has nothing to close.
+func (l *Logger) Close() error {
+	if l.opts.collapser != nil {
+		l.opts.collapser.flush()
+	}
+
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+	var err error
+	if bw, ok := l.output.(*batchWriter); ok {
+		err = bw.Close()
+	}
+	for _, c := range l.opts.closers {
+		if e := c.Close(); e != nil && err == nil {
+			err = e
+		}
+	}
+	return err
 }
 
 // Enabled tests whether this Logger is enabled.  For example, commandline
@@ -174,7 +466,7 @@ func (l *Logger) SetOutput(w io.Writer) {
 func (l *Logger) Enabled() bool {
 	l.mtx.RLock()
 	defer l.mtx.RUnlock()
-	return l.verbosity <= Verbosity(logLevel)
+	return l.opts.unfiltered || l.verbosity <= Verbosity(logLevel)
 }
 
 func sourcePath(file string) string {
@@ -186,26 +478,255 @@ func sourcePath(file string) string {
 	return filepath.Join(filepath.Base(filepath.Dir(file)), filepath.Base(file))
 }
 
+// pkgPrefix prefixes the names of all functions of this package
+var pkgPrefix = reflect.TypeOf(Logger{}).PkgPath() + "."
+
+// slogPrefix prefixes the names of all functions of log/slog, which are
+// skipped like those of this package when logging through SlogHandler
+const slogPrefix = "log/slog."
+
+// caller returns the file and line of the first caller outside of this
+// package and log/slog
+func caller() (string, int) {
+	var pcs [16]uintptr
+	n := runtime.Callers(2, pcs[:])
+	frames := runtime.CallersFrames(pcs[:n])
+	for {
+		frame, more := frames.Next()
+		if !strings.HasPrefix(frame.Function, pkgPrefix) && !strings.HasPrefix(frame.Function, slogPrefix) {
+			return frame.File, frame.Line
+		}
+		if !more {
+			return frame.File, frame.Line
+		}
+	}
+}
+
+// encoderFor returns the encoder set with WithEncoderForLevel for the level
+// of m or the encoder of l
+func (l *Logger) encoderFor(m Entry) Encoder {
+	if len(l.opts.levelEncoders) == 0 {
+		return l.encoder
+	}
+	if m.Error != nil {
+		if enc, ok := l.opts.levelEncoders[ErrorLevel]; ok {
+			return enc
+		}
+	}
+	if enc, ok := l.opts.levelEncoders[int(m.Verbosity)]; ok {
+		return enc
+	}
+	return l.encoder
+}
+
+// outputFor returns the output set with WithOutputForLevel for the level of m
+// or the output of l. l.mtx must be held
+func (l *Logger) outputFor(m Entry) io.Writer {
+	if len(l.opts.levelOutputs) == 0 {
+		return l.output
+	}
+	if m.Error != nil {
+		if w, ok := l.opts.levelOutputs[ErrorLevel]; ok {
+			return w
+		}
+	}
+	if w, ok := l.opts.levelOutputs[int(m.Verbosity)]; ok {
+		return w
+	}
+	return l.output
+}
+
 // log will log the message. It DOES NOT check Enabled() first so that should
 // be checked by it's callers
-func (l *Logger) log(msg string, context map[string]interface{}) {
-	_, file, line, _ := runtime.Caller(3)
+//
+// If at is zero the entry is logged at the current time, see TimestampFunc
+func (l *Logger) log(at time.Time, msg string, context map[string]interface{}, err error) {
+	if l.limited() {
+		return
+	}
+	file, line := caller()
 	file = sourcePath(file)
-	m := Line{
-		Timestamp: TimestampFunc(),
-		FileLine:  fmt.Sprintf("%s:%s", file, strconv.Itoa(line)),
-		Verbosity: l.verbosity.String(),
+
+	context = l.withFields(context)
+	if l.opts.registry != nil {
+		l.opts.registry.withDynamicFields(context)
+	}
+	l.formatValues(context)
+	redactStructs(context)
+	if l.opts.maxFields > 0 {
+		context = l.truncated(context)
+	}
+	if l.opts.maxArrayElements > 0 {
+		l.truncateArrays(context)
+	}
+	if l.opts.levelNumericKey != "" {
+		context[l.opts.levelNumericKey] = int(l.verbosity)
+	}
+	if l.opts.seq != nil {
+		context[SequenceKey] = atomic.AddUint64(l.opts.seq, 1) - 1
+	}
+	if l.opts.goroutineID {
+		context[GoroutineIDKey] = goroutineID()
+	}
+
+	var ts string
+	switch {
+	case l.opts.timestampPrecision > 0:
+		if at.IsZero() {
+			at = time.Now()
+		}
+		at = at.Truncate(l.opts.timestampPrecision)
+		ts = formatTimestamp(at)
+	case at.IsZero():
+		at, ts = now()
+	default:
+		ts = formatTimestamp(at)
+	}
+	if l.opts.timePartitionFields {
+		utc := at.UTC()
+		context[DateKey] = utc.Format("2006-01-02")
+		context[HourKey] = utc.Hour()
+	}
+	if l.opts.localTimestampKey != "" {
+		context[l.opts.localTimestampKey] = at.Local().Format(time.RFC3339Nano)
+	}
+
+	if l.opts.floatNotation != 0 {
+		l.formatFloats(context)
+	}
+	if l.opts.numbersAsStrings || l.opts.builtinNumbersAsStrings {
+		l.quoteNumbers(context)
+	}
+	if len(l.opts.replaceFields) > 0 {
+		context = l.replaced(context)
+	}
+	if l.opts.allowedKeys != nil {
+		l.allowed(context)
+	}
+	if l.opts.fieldNamespace != "" {
+		context = l.namespaced(context)
+	}
+
+	m := Entry{
+		Time:      at,
+		Timestamp: ts,
+		Level:     l.level(),
+		Verbosity: l.verbosity,
 		Component: l.name,
 		Message:   msg,
-		Context:   context,
+		Fields:    context,
+		Labels:    l.labels,
+		Error:     err,
+		Caller:    fmt.Sprintf("%s:%s", file, strconv.Itoa(line)),
 	}
-
-	err := l.encoder.Encode(l.output, m)
 	if err != nil {
-		// expand first so we can quote later
-		orig := fmt.Sprintf("%#v", m)
-		_, _ = fmt.Fprintf(l.output, `{"message","failed to encode message", "encoder":"%T","log":%q,"cause":%q}`, l.encoder, orig, err)
+		m.Stack = kverrors.Stack(err)
+	}
+
+	if l.opts.runtimeTrace {
+		l.traceLog(msg)
+	}
+
+	if l.opts.registry != nil {
+		l.opts.registry.runHooks(m)
+	}
+	if l.opts.buffer != nil && l.opts.buffer.add(l, m) {
+		return
+	}
+	if l.opts.collapser != nil && l.opts.collapser.collapse(l, m) {
+		l.opts.stats.suppress()
+		return
+	}
+	l.emit(m)
+}
+
+// emit writes m to the output and all fanout targets
+func (l *Logger) emit(m Entry) {
+	l.opts.stats.entry()
+	l.mtx.RLock()
+	enc, w := l.encoderFor(m), l.outputFor(m)
+	l.mtx.RUnlock()
+	l.write(enc, w, m)
+	if m.Error != nil && l.opts.errorMirror != nil && l.opts.errorMirror != w {
+		l.write(enc, l.opts.errorMirror, m)
+	}
+	for _, t := range l.opts.fanout {
+		l.write(t.Encoder, t.Output, m)
+	}
+}
+
+// write encodes m with enc to w. If encoding fails a description of the
+// failure is written to w instead
+func (l *Logger) write(enc Encoder, w io.Writer, m Entry) {
+	_, journald := enc.(JournaldEncoder)
+	if journald || l.opts.lineTerminator == nil && l.opts.keepInvalidUTF8 {
+		if e := l.encode(enc, w, m); e != nil {
+			_, _ = w.Write(encodeFailure(enc, m, e))
+		}
+		return
 	}
+
+	// the entry is buffered so that it is terminated and sanitized as a
+	// whole however many writes the encoder splits it into
+	buf := &bytes.Buffer{}
+	if e := l.encode(enc, buf, m); e != nil {
+		buf.Reset()
+		buf.Write(encodeFailure(enc, m, e))
+	}
+	b := buf.Bytes()
+	if l.opts.lineTerminator != nil {
+		b = terminate(b, *l.opts.lineTerminator)
+	}
+	if !l.opts.keepInvalidUTF8 {
+		b = sanitizeUTF8(b)
+	}
+	_, _ = w.Write(b)
+}
+
+// encodeFailure returns a JSON line describing that enc failed to encode m
+// with err
+func encodeFailure(enc Encoder, m Entry, err error) []byte {
+	b, e := json.Marshal(map[string]interface{}{
+		"message": "failed to encode message",
+		"encoder": fmt.Sprintf("%T", enc),
+		"log":     fmt.Sprintf("%#v", m),
+		"cause":   err.Error(),
+	})
+	if e != nil {
+		return []byte(`{"message":"failed to encode message"}` + "\n")
+	}
+	return append(b, '\n')
+}
+
+// encode encodes m with enc to w. If the encoder panics, the panic is
+// recovered and passed to the panic handler, or a minimal entry describing
+// the panic is written instead so that logging never takes down the caller.
+func (l *Logger) encode(enc Encoder, w io.Writer, m Entry) (err error) {
+	defer func() {
+		r := recover()
+		if r == nil {
+			if err != nil {
+				l.opts.stats.encodeFailed()
+			}
+			return
+		}
+		l.opts.stats.encodeFailed()
+		if l.opts.panicHandler != nil {
+			l.opts.panicHandler(r)
+			return
+		}
+		b, e := json.Marshal(map[string]interface{}{
+			LevelKey:   m.Level,
+			MessageKey: "log encode panic",
+			PanicKey:   fmt.Sprint(r),
+		})
+		if e != nil {
+			err = e
+			return
+		}
+		_, err = w.Write(append(b, '\n'))
+	}()
+	return enc.Encode(w, m)
 }
 
 // Info logs a non-error message with the given key/value pairs as context.
@@ -218,7 +739,16 @@ func (l *Logger) Info(msg string, keysAndValues ...interface{}) {
 	if !l.Enabled() {
 		return
 	}
-	l.log(msg, combine(l.context, keysAndValues...))
+	l.log(time.Time{}, msg, l.combine(keysAndValues...), nil)
+}
+
+// InfoAt logs a non-error message like Info but with t as the time of the
+// entry instead of the current time, e.g. to backfill historical events.
+func (l *Logger) InfoAt(t time.Time, msg string, keysAndValues ...interface{}) {
+	if !l.Enabled() {
+		return
+	}
+	l.log(t, msg, l.combine(keysAndValues...), nil)
 }
 
 // Error logs an error, with the given message and key/value pairs as context.
@@ -234,19 +764,27 @@ func (l *Logger) Error(err error, msg string, keysAndValues ...interface{}) {
 		return
 	}
 
-	if err == nil {
-		l.Info(msg, keysAndValues)
-		return
+	context := l.combine(keysAndValues...)
+	if err != nil {
+		status, hasStatus := kverrors.Status(err)
+		logged := err
+		switch logged.(type) {
+		case *kverrors.KVError:
+			// nothing to be done
+		default:
+			logged = kverrors.New(err.Error())
+		}
+		// surface the status of a cause as the status of the logged error
+		if hasStatus && kverrors.KVs(logged)[kverrors.StatusKey] != status {
+			logged = kverrors.WithStatus(logged, status)
+		}
+		context[l.errorKey()] = logged
 	}
 
-	switch err.(type) {
-	case *kverrors.KVError:
-		// nothing to be done
-	default:
-		err = kverrors.New(err.Error())
+	l.log(time.Time{}, msg, context, err)
+	if l.opts.flushOnError {
+		_ = l.Flush()
 	}
-
-	l.Info(msg, append(keysAndValues, ErrorKey, err)...)
 }
 
 // V returns an Logger value for a specific verbosity level, relative to
@@ -254,7 +792,9 @@ func (l *Logger) Error(err error, msg string, keysAndValues ...interface{}) {
 // level means a log message is less important.  It's illegal to pass a log
 // level less than zero.
 func (l *Logger) V(v int) logr.Logger {
-	return NewLogger(l.name, l.output, Verbosity(v)+l.verbosity, l.encoder, l.context)
+	ll := l.clone()
+	ll.verbosity += Verbosity(v)
+	return ll
 }
 
 // WithName adds a new element to the logger's name.
@@ -262,18 +802,22 @@ func (l *Logger) V(v int) logr.Logger {
 // suffixes to the logger's name.  It's strongly recommended
 // that name segments contain only letters, digits, and hyphens
 // (see the package documentation for more information).
+//
+// The name is logged as the component: the component passed to Init is the
+// base and each name is appended with NameSeparator, e.g. Init("app") followed
+// by WithName("controller").WithName("sync") logs the component
+// "app_controller_sync". Empty names are ignored.
 func (l *Logger) WithName(name string) logr.Logger {
-	newName := name
-
-	if l.name != "" {
-		newName = fmt.Sprintf("%s_%s", l.name, name)
+	newName := l.name
+	switch {
+	case name == "":
+	case l.name == "":
+		newName = name
+	default:
+		newName = l.name + NameSeparator + name
 	}
 
-	return NewLogger(
-		newName,
-		l.output,
-		l.verbosity,
-		l.encoder,
-		l.context,
-	)
+	ll := l.clone()
+	ll.name = newName
+	return ll
 }
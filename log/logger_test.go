@@ -8,6 +8,8 @@ import (
 	"io/ioutil"
 	"math"
 	"reflect"
+	"strings"
+	"sync"
 	"testing"
 
 	"github.com/ViaQ/logerr/kverrors"
@@ -216,6 +218,19 @@ func TestLogger_Error_WorksWithNilError(t *testing.T) {
 	assert.Nil(t, logs[0].Error)
 }
 
+func TestLogger_Error_NilErrorKeepsKeysAndValues(t *testing.T) {
+	obs, logger := NewObservedLogger()
+	log.WithStrictKeys(true)(logger)
+
+	logger.Error(nil, t.Name(), "user", "alice")
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 1)
+	assert.Equal(t, "alice", logs[0].Context["user"])
+	assert.NotContains(t, logs[0].Context, log.ErrorKey)
+	assert.NotContains(t, logs[0].Context, log.StrictKeysKey)
+}
+
 func TestLogger_V_Info(t *testing.T) {
 	for verbosity := 1; verbosity < 5; verbosity++ {
 		log.SetLogLevel(verbosity)
@@ -381,3 +396,100 @@ func TestLogger_LogLineWithNoContext(t *testing.T) {
 	assert.Nil(t, err)
 	assert.Contains(t, string(buf), fmt.Sprintf(`%q:%q`, log.MessageKey,msg))
 }
+
+func TestLogger_WithSequenceNumbers_UniqueAndContiguous(t *testing.T) {
+	const workers, entries = 8, 50
+
+	buf := &syncBuffer{}
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	log.WithSequenceNumbers(true)(logger)
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			ll := logger.WithValues("worker", i)
+			for j := 0; j < entries; j++ {
+				ll.Info("hello, world")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	seen := map[uint64]bool{}
+	dec := json.NewDecoder(strings.NewReader(buf.String()))
+	for dec.More() {
+		var entry struct {
+			Seq *uint64 `json:"_seq"`
+		}
+		require.NoError(t, dec.Decode(&entry))
+		require.NotNil(t, entry.Seq, "expected %q in entry", log.SequenceKey)
+		require.False(t, seen[*entry.Seq], "duplicate sequence number %d", *entry.Seq)
+		seen[*entry.Seq] = true
+	}
+
+	require.Len(t, seen, workers*entries)
+	for i := uint64(0); i < workers*entries; i++ {
+		require.True(t, seen[i], "missing sequence number %d", i)
+	}
+}
+
+func TestLogger_WithSequenceNumbers_DisabledByDefault(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	logger.Info("hello, world")
+
+	assert.NotContains(t, buf.String(), log.SequenceKey)
+}
+
+// panickingValue panics when it is encoded
+type panickingValue struct{}
+
+func (panickingValue) MarshalJSON() ([]byte, error) {
+	panic("pathological marshaler")
+}
+
+func TestLogger_RecoversEncoderPanic(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+
+	require.NotPanics(t, func() {
+		logger.Info("hello, world", "value", panickingValue{})
+	})
+
+	m := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &m), buf.String())
+	assert.Equal(t, "log encode panic", m[log.MessageKey])
+	assert.Equal(t, "pathological marshaler", m[log.PanicKey])
+}
+
+func TestLogger_WithPanicHandler(t *testing.T) {
+	var recovered interface{}
+
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	log.WithPanicHandler(func(r interface{}) {
+		recovered = r
+	})(logger)
+
+	logger.Info("hello, world", "value", panickingValue{})
+
+	assert.Equal(t, "pathological marshaler", recovered)
+	assert.Zero(t, buf.Len())
+}
+
+func TestLogger_FileLine_IsCaller(t *testing.T) {
+	obs, logger := NewObservedLogger()
+
+	logger.Info("hello, world")
+	logger.Error(io.ErrUnexpectedEOF, "hello, world")
+	log.UseLogger(logger)
+	log.Info("hello, world")
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 3)
+	for _, entry := range logs {
+		assert.Contains(t, entry.FileLine, "logger_test.go")
+	}
+}
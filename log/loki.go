@@ -0,0 +1,329 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ViaQ/logerr/kverrors"
+)
+
+// LokiPushPath is the path of Loki's push API
+const LokiPushPath = "/loki/api/v1/push"
+
+// ErrLokiWriterClosed is returned when writing to a closed LokiWriter
+var ErrLokiWriterClosed = kverrors.New("loki writer is closed")
+
+// LokiOption configures a LokiWriter
+type LokiOption func(*LokiWriter)
+
+// LokiBasicAuth authenticates the pushes with username and password
+func LokiBasicAuth(username, password string) LokiOption {
+	return func(w *LokiWriter) {
+		w.username, w.password = username, password
+	}
+}
+
+// LokiTenant sends the pushes for the tenant id of a multi-tenant Loki
+func LokiTenant(id string) LokiOption {
+	return func(w *LokiWriter) {
+		w.tenant = id
+	}
+}
+
+// LokiBatch pushes the buffered entries every interval or as soon as they
+// reach maxBytes, whichever comes first. The default is every second or 1MiB.
+// A non-positive interval only pushes when maxBytes is reached and on Flush.
+func LokiBatch(interval time.Duration, maxBytes int) LokiOption {
+	return func(w *LokiWriter) {
+		w.interval, w.maxBytes = interval, maxBytes
+	}
+}
+
+// LokiRetry retries pushes that fail with a network error, 429 or a 5xx
+// status up to attempts times, waiting backoff before the first retry and
+// twice as long before each following one. The default is 3 retries starting
+// at 100ms.
+func LokiRetry(attempts int, backoff time.Duration) LokiOption {
+	return func(w *LokiWriter) {
+		w.retries, w.backoff = attempts, backoff
+	}
+}
+
+// LokiHTTPClient pushes with client instead of http.DefaultClient
+func LokiHTTPClient(client *http.Client) LokiOption {
+	return func(w *LokiWriter) {
+		w.client = client
+	}
+}
+
+// WithLoki writes entries to the Loki at url, e.g. http://loki:3100, with a
+// LokiWriter configured by opts. Close flushes the buffered entries. See
+// NewLokiWriter
+func WithLoki(url string, opts ...LokiOption) Option {
+	return func(l *Logger) {
+		w := NewLokiWriter(url, opts...)
+		l.SetOutput(w)
+		l.opts.closers = append(append([]io.Closer{}, l.opts.closers...), w)
+	}
+}
+
+// LokiWriter buffers the JSON entries written to it and pushes them in
+// batches to Loki's push API. Each line is pushed as it is, to the stream of
+// the labels of the entry, see WithLabels, at the time it was written. Pushes
+// that fail for transient reasons are retried, see LokiRetry. Entries that
+// can't be delivered are dropped and the error is returned by the next Flush
+// or Close.
+type LokiWriter struct {
+	url      string
+	username string
+	password string
+	tenant   string
+	client   *http.Client
+	interval time.Duration
+	maxBytes int
+	retries  int
+	backoff  time.Duration
+
+	mtx     sync.Mutex
+	batch   []lokiLine
+	size    int
+	err     error
+	closed  bool
+	pushMtx sync.Mutex
+	kick    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+}
+
+// lokiLine is a line of a batch with the time it was written
+type lokiLine struct {
+	at     time.Time
+	labels map[string]string
+	line   string
+}
+
+// NewLokiWriter creates a LokiWriter that pushes to the Loki at url
+func NewLokiWriter(url string, opts ...LokiOption) *LokiWriter {
+	w := &LokiWriter{
+		url:      strings.TrimSuffix(url, "/") + LokiPushPath,
+		client:   http.DefaultClient,
+		interval: time.Second,
+		maxBytes: 1 << 20,
+		retries:  3,
+		backoff:  100 * time.Millisecond,
+		kick:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	go w.run()
+	return w
+}
+
+// Write buffers the JSON entries, one per line, in p
+func (w *LokiWriter) Write(p []byte) (int, error) {
+	now := time.Now()
+	var lines []lokiLine
+	for _, line := range bytes.Split(p, []byte{'\n'}) {
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+		var entry struct {
+			// the tag must match LabelsKey
+			Labels map[string]string `json:"_labels"`
+		}
+		// lines that are not entries are pushed without labels
+		_ = json.Unmarshal(line, &entry)
+		lines = append(lines, lokiLine{at: now, labels: entry.Labels, line: string(line)})
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.closed {
+		return 0, ErrLokiWriterClosed
+	}
+	for _, l := range lines {
+		w.batch = append(w.batch, l)
+		w.size += len(l.line)
+	}
+	if w.maxBytes > 0 && w.size >= w.maxBytes {
+		select {
+		case w.kick <- struct{}{}:
+		default:
+		}
+	}
+	return len(p), nil
+}
+
+// Pending returns the number of entries waiting to be pushed
+func (w *LokiWriter) Pending() int {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	return len(w.batch)
+}
+
+// run pushes the batch every interval or when kicked until stopped
+func (w *LokiWriter) run() {
+	defer close(w.done)
+
+	var tick <-chan time.Time
+	if w.interval > 0 {
+		ticker := time.NewTicker(w.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-tick:
+		case <-w.kick:
+		case <-w.stop:
+			return
+		}
+		_ = w.Flush()
+	}
+}
+
+// Flush pushes the buffered entries and returns the error of any push that
+// failed since the last Flush
+func (w *LokiWriter) Flush() error {
+	w.pushMtx.Lock()
+	defer w.pushMtx.Unlock()
+
+	w.mtx.Lock()
+	batch := w.batch
+	w.batch, w.size = nil, 0
+	w.mtx.Unlock()
+
+	if len(batch) > 0 {
+		if err := w.push(batch); err != nil {
+			w.mtx.Lock()
+			w.err = err
+			w.mtx.Unlock()
+		}
+	}
+
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	err := w.err
+	w.err = nil
+	return err
+}
+
+// Close pushes the buffered entries and stops the writer
+func (w *LokiWriter) Close() error {
+	w.mtx.Lock()
+	if w.closed {
+		w.mtx.Unlock()
+		return nil
+	}
+	w.closed = true
+	w.mtx.Unlock()
+
+	close(w.stop)
+	<-w.done
+	return w.Flush()
+}
+
+// push sends batch to Loki, retrying transient failures
+func (w *LokiWriter) push(batch []lokiLine) error {
+	body, err := json.Marshal(lokiPayload(batch))
+	if err != nil {
+		return err
+	}
+
+	backoff := w.backoff
+	for attempt := 0; ; attempt++ {
+		retry, err := w.send(body)
+		if err == nil {
+			return nil
+		}
+		if !retry || attempt >= w.retries {
+			return err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// send posts body once and reports whether a failure is worth retrying
+func (w *LokiWriter) send(body []byte) (bool, error) {
+	req, err := http.NewRequest(http.MethodPost, w.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if w.username != "" || w.password != "" {
+		req.SetBasicAuth(w.username, w.password)
+	}
+	if w.tenant != "" {
+		req.Header.Set("X-Scope-OrgID", w.tenant)
+	}
+
+	resp, err := w.client.Do(req)
+	if err != nil {
+		return true, kverrors.Wrap(err, "failed to push to loki", "url", w.url)
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, resp.Body)
+
+	if resp.StatusCode/100 == 2 {
+		return false, nil
+	}
+	retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode/100 == 5
+	return retry, kverrors.New("loki rejected push", "url", w.url, "status", resp.StatusCode)
+}
+
+// lokiStream is a stream of Loki's push API
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// lokiPayload groups batch by labels into the body of a push
+func lokiPayload(batch []lokiLine) map[string][]lokiStream {
+	var (
+		streams []lokiStream
+		index   = map[string]int{}
+	)
+	for _, l := range batch {
+		key := labelsKey(l.labels)
+		i, ok := index[key]
+		if !ok {
+			stream := l.labels
+			if stream == nil {
+				stream = map[string]string{}
+			}
+			i = len(streams)
+			index[key] = i
+			streams = append(streams, lokiStream{Stream: stream})
+		}
+		ts := strconv.FormatInt(l.at.UnixNano(), 10)
+		streams[i].Values = append(streams[i].Values, [2]string{ts, l.line})
+	}
+	return map[string][]lokiStream{"streams": streams}
+}
+
+// labelsKey returns a key that identifies the set of labels
+func labelsKey(labels map[string]string) string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, strconv.Quote(k)+"="+strconv.Quote(labels[k]))
+	}
+	return strings.Join(parts, ",")
+}
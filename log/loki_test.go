@@ -0,0 +1,140 @@
+package log_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+// lokiPush is the body of a push to Loki
+type lokiPush struct {
+	Streams []struct {
+		Stream map[string]string `json:"stream"`
+		Values [][2]string       `json:"values"`
+	} `json:"streams"`
+}
+
+// fakeLoki records the pushes it receives and fails the first failures of
+// them with status
+type fakeLoki struct {
+	mtx      sync.Mutex
+	pushes   []lokiPush
+	requests []*http.Request
+	failures int
+	status   int
+}
+
+func (f *fakeLoki) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.requests = append(f.requests, r)
+	if f.failures > 0 {
+		f.failures--
+		w.WriteHeader(f.status)
+		return
+	}
+	var push lokiPush
+	if err := json.NewDecoder(r.Body).Decode(&push); err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	f.pushes = append(f.pushes, push)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (f *fakeLoki) Pushes() []lokiPush {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	return append([]lokiPush(nil), f.pushes...)
+}
+
+func TestWithLoki_PushesStreamsOnClose(t *testing.T) {
+	loki := &fakeLoki{}
+	srv := httptest.NewServer(loki)
+	defer srv.Close()
+
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithLoki(srv.URL, log.LokiBatch(time.Hour, 1<<20), log.LokiBasicAuth("user", "secret"), log.LokiTenant("team-a")),
+	})
+
+	log.WithLabels("app", "billing").Info("first")
+	log.WithLabels("app", "billing").Info("second")
+	log.WithLabels("app", "shipping").Info("third")
+	require.Empty(t, loki.Pushes(), "expected entries to be batched")
+
+	require.NoError(t, log.Close())
+
+	pushes := loki.Pushes()
+	require.Len(t, pushes, 1)
+	streams := pushes[0].Streams
+	require.Len(t, streams, 2)
+	require.Equal(t, map[string]string{"app": "billing"}, streams[0].Stream)
+	require.Len(t, streams[0].Values, 2)
+	require.Equal(t, map[string]string{"app": "shipping"}, streams[1].Stream)
+	require.Len(t, streams[1].Values, 1)
+
+	line := decodeEntry(t, []byte(streams[0].Values[0][1]))
+	require.Equal(t, "first", line[log.MessageKey])
+	require.NotEmpty(t, streams[0].Values[0][0])
+
+	r := loki.requests[0]
+	require.Equal(t, log.LokiPushPath, r.URL.Path)
+	require.Equal(t, "team-a", r.Header.Get("X-Scope-OrgID"))
+	user, pass, ok := r.BasicAuth()
+	require.True(t, ok)
+	require.Equal(t, "user", user)
+	require.Equal(t, "secret", pass)
+}
+
+func TestLokiWriter_PushesWhenBatchIsFull(t *testing.T) {
+	loki := &fakeLoki{}
+	srv := httptest.NewServer(loki)
+	defer srv.Close()
+
+	w := log.NewLokiWriter(srv.URL, log.LokiBatch(0, 1))
+	defer func() { require.NoError(t, w.Close()) }()
+
+	logger := log.NewLogger("test", w, 0, log.JSONEncoder{})
+	logger.Info("hello")
+
+	require.Eventually(t, func() bool {
+		return len(loki.Pushes()) == 1
+	}, time.Second, 5*time.Millisecond)
+}
+
+func TestLokiWriter_RetriesTransientFailures(t *testing.T) {
+	loki := &fakeLoki{failures: 2, status: http.StatusServiceUnavailable}
+	srv := httptest.NewServer(loki)
+	defer srv.Close()
+
+	w := log.NewLokiWriter(srv.URL, log.LokiBatch(0, 1<<20), log.LokiRetry(3, time.Millisecond))
+	_, err := w.Write([]byte(`{"_message":"hello"}` + "\n"))
+	require.NoError(t, err)
+
+	require.NoError(t, w.Close())
+	require.Len(t, loki.Pushes(), 1)
+	require.Len(t, loki.requests, 3)
+
+	_, err = w.Write([]byte("{}\n"))
+	require.Equal(t, log.ErrLokiWriterClosed, err)
+}
+
+func TestLokiWriter_ReportsPermanentFailures(t *testing.T) {
+	loki := &fakeLoki{failures: 1, status: http.StatusBadRequest}
+	srv := httptest.NewServer(loki)
+	defer srv.Close()
+
+	w := log.NewLokiWriter(srv.URL, log.LokiBatch(0, 1<<20), log.LokiRetry(3, time.Millisecond))
+	_, err := w.Write([]byte(`{"_message":"hello"}` + "\n"))
+	require.NoError(t, err)
+
+	require.Error(t, w.Flush())
+	require.Len(t, loki.requests, 1, "expected no retries")
+	require.NoError(t, w.Close())
+}
@@ -0,0 +1,46 @@
+package log
+
+import (
+	"github.com/ViaQ/logerr/kverrors"
+)
+
+// DefaultErrorCode is counted for logged errors without a code. See
+// WithErrorCodeMetrics
+const DefaultErrorCode = "unknown"
+
+// ErrorCodeCounter counts logged errors by their code
+type ErrorCodeCounter interface {
+	Inc(code string)
+}
+
+// ErrorCodeCounterFunc adapts a function to ErrorCodeCounter
+type ErrorCodeCounterFunc func(code string)
+
+// Inc implements ErrorCodeCounter
+func (f ErrorCodeCounterFunc) Inc(code string) {
+	f(code)
+}
+
+// WithErrorCodeMetrics counts every logged error with counter by the code
+// found with kverrors.Code, or DefaultErrorCode if it has none. It is a hook,
+// see WithHook, and keeps this package free of a metrics dependency. With
+// Prometheus, for example:
+//
+//	errorsTotal := prometheus.NewCounterVec(prometheus.CounterOpts{
+//		Name: "errors_total",
+//	}, []string{"code"})
+//	log.WithErrorCodeMetrics(log.ErrorCodeCounterFunc(func(code string) {
+//		errorsTotal.WithLabelValues(code).Inc()
+//	}))
+func WithErrorCodeMetrics(counter ErrorCodeCounter) Option {
+	return WithHook(func(m Entry) {
+		if m.Error == nil {
+			return
+		}
+		code, ok := kverrors.Code(m.Error)
+		if !ok {
+			code = DefaultErrorCode
+		}
+		counter.Inc(code)
+	})
+}
@@ -0,0 +1,39 @@
+package log_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sync"
+	"testing"
+
+	"github.com/ViaQ/logerr/kverrors"
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeCounter is an ErrorCodeCounter that records the counts in memory
+type fakeCounter struct {
+	mtx    sync.Mutex
+	counts map[string]int
+}
+
+func (f *fakeCounter) Inc(code string) {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+	f.counts[code]++
+}
+
+func TestWithErrorCodeMetrics(t *testing.T) {
+	counter := &fakeCounter{counts: map[string]int{}}
+	logger := log.NewLogger("test", ioutil.Discard, 0, log.JSONEncoder{})
+	log.WithErrorCodeMetrics(counter)(logger)
+
+	errNotFound := kverrors.NewSentinel("not_found", "not found")
+	logger.Error(kverrors.Wrap(errNotFound, "lookup failed"), "failed")
+	logger.Error(fmt.Errorf("lookup failed: %w", errNotFound), "failed")
+	logger.Error(errors.New("fail boat"), "failed")
+	logger.Info("hello, world")
+
+	require.Equal(t, map[string]int{"not_found": 2, log.DefaultErrorCode: 1}, counter.counts)
+}
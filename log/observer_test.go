@@ -7,7 +7,6 @@ import (
 	"io"
 	"io/ioutil"
 	"os"
-	"strconv"
 	"testing"
 	"time"
 
@@ -122,11 +121,9 @@ func (o *observableEncoder) Reset() {
 // everything else in the Context field
 func parseEntry(entry interface{}) *observedEntry {
 	// Make a copy, don't alter the argument as a side effect.
-	m := entry.(log.Line)
-	verbosity, err := strconv.Atoi(m.Verbosity)
-	if err != nil {
-		log.Error(err, "failed to parse string as verbosity")
-	}
+	e := entry.(log.Entry)
+	m := e.Line()
+	verbosity := e.Verbosity
 
 	var resultErr error= nil
 	if errVal, ok := m.Context[log.ErrorKey]; ok {
@@ -139,7 +136,7 @@ func parseEntry(entry interface{}) *observedEntry {
 	result := &observedEntry{
 		Timestamp: m.Timestamp,
 		FileLine: m.FileLine,
-		Verbosity: log.Verbosity(verbosity),
+		Verbosity: verbosity,
 		Component: m.Component,
 		Message: m.Message,
 		Context: m.Context,
@@ -0,0 +1,86 @@
+package log
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"github.com/ViaQ/logerr/kverrors"
+)
+
+var (
+	onceMtx sync.Mutex
+	// onceLogged are the keys of Once that an entry was logged for and
+	// everyLogged is when an entry was last logged for a key of Every. The
+	// key spaces are separate and never pruned, see Once and Every
+	onceLogged  = map[string]struct{}{}
+	everyLogged = map[string]time.Time{}
+)
+
+// Once logs a non-error message like Info, but only the first time it is
+// called with key. This is useful for warnings that would otherwise be
+// repeated, e.g. deprecation notices.
+//
+// Every key is remembered for the life of the process, so keys should be
+// static or drawn from a small bounded set.
+func Once(key, msg string, keysAndValues ...interface{}) {
+	if !allowOnce(key) {
+		return
+	}
+	mtx.RLock()
+	defer mtx.RUnlock()
+	root().Info(msg, keysAndValues...)
+}
+
+// Every logs a non-error message like Info, but at most once every d for key.
+// d must be positive. Otherwise only the first call for key is logged, like
+// with Once, as an error wrapping ErrInvalidOption so that the mistake doesn't
+// go unnoticed.
+//
+// Every key is remembered for the life of the process, so keys should be
+// static or drawn from a small bounded set.
+func Every(key string, d time.Duration, msg string, keysAndValues ...interface{}) {
+	if d <= 0 {
+		if !allowEvery(key, math.MaxInt64) {
+			return
+		}
+		mtx.RLock()
+		defer mtx.RUnlock()
+		err := kverrors.Add(ErrInvalidOption, "option", "interval", "reason", "must be positive", "value", d.String())
+		root().Error(err, msg, keysAndValues...)
+		return
+	}
+	if !allowEvery(key, d) {
+		return
+	}
+	mtx.RLock()
+	defer mtx.RUnlock()
+	root().Info(msg, keysAndValues...)
+}
+
+// allowOnce reports whether an entry may be logged for key of Once and
+// records that it was
+func allowOnce(key string) bool {
+	onceMtx.Lock()
+	defer onceMtx.Unlock()
+
+	if _, ok := onceLogged[key]; ok {
+		return false
+	}
+	onceLogged[key] = struct{}{}
+	return true
+}
+
+// allowEvery reports whether an entry may be logged for key of Every with
+// the interval d and records that it was
+func allowEvery(key string, d time.Duration) bool {
+	onceMtx.Lock()
+	defer onceMtx.Unlock()
+
+	now := time.Now()
+	if last, ok := everyLogged[key]; ok && now.Sub(last) < d {
+		return false
+	}
+	everyLogged[key] = now
+	return true
+}
@@ -0,0 +1,73 @@
+package log_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ViaQ/logerr/kverrors"
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOnce_LogsOnlyOnce(t *testing.T) {
+	t.Cleanup(log.ResetOnce)
+	obs, logger := NewObservedLogger()
+	log.UseLogger(logger)
+
+	for i := 0; i < 3; i++ {
+		log.Once(t.Name(), "deprecated", "attempt", i)
+	}
+	log.Once(t.Name()+"-other", "deprecated")
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 2)
+	require.EqualValues(t, 0, logs[0].Context["attempt"])
+}
+
+func TestEvery_SuppressesWithinWindow(t *testing.T) {
+	t.Cleanup(log.ResetOnce)
+	obs, logger := NewObservedLogger()
+	log.UseLogger(logger)
+
+	const window = 50 * time.Millisecond
+
+	log.Every(t.Name(), window, "hello, world")
+	log.Every(t.Name(), window, "hello, world")
+	require.Len(t, obs.TakeAll(), 1)
+
+	time.Sleep(window)
+	log.Every(t.Name(), window, "hello, world")
+	require.Len(t, obs.TakeAll(), 1)
+}
+
+func TestEvery_RejectsNonPositiveInterval(t *testing.T) {
+	t.Cleanup(log.ResetOnce)
+	obs, logger := NewObservedLogger()
+	log.UseLogger(logger)
+
+	for _, d := range []time.Duration{0, -time.Second, -time.Nanosecond} {
+		log.Every(t.Name(), d, "hello, world")
+		log.Every(t.Name()+d.String(), d, "hello, world")
+	}
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 4, "expected one entry for each key")
+	for _, entry := range logs {
+		require.Equal(t, log.ErrInvalidOption, kverrors.Root(entry.Error))
+	}
+}
+
+func TestOnceAndEvery_SeparateKeys(t *testing.T) {
+	t.Cleanup(log.ResetOnce)
+	obs, logger := NewObservedLogger()
+	log.UseLogger(logger)
+
+	log.Every(t.Name(), time.Hour, "every")
+	log.Once(t.Name(), "once")
+	log.Every(t.Name(), time.Hour, "every")
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 2)
+	require.Equal(t, "every", logs[0].Message)
+	require.Equal(t, "once", logs[1].Message)
+}
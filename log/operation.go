@@ -0,0 +1,54 @@
+package log
+
+import (
+	"time"
+
+	"github.com/go-logr/logr"
+)
+
+// Keys of the fields added by Operation
+const (
+	OperationKey      = "operation"
+	DurationMillisKey = "duration_ms"
+)
+
+// ElapsedMillisKey is the key of the elapsed time logged by Timer
+const ElapsedMillisKey = "elapsed_ms"
+
+// Operation returns a logger for the operation name and a function that logs
+// its completion. The logger and the completion entry have name under
+// OperationKey, and the completion entry has the time since Operation was
+// called in milliseconds under DurationMillisKey. If done is called with a
+// non-nil error the completion is logged with Error.
+//
+//	logger, done := log.Operation("sync")
+//	err := sync(logger)
+//	done(err)
+func Operation(name string) (logger logr.Logger, done func(err error)) {
+	start := time.Now()
+	logger = WithValues(OperationKey, name)
+	return logger, func(err error) {
+		duration := time.Since(start).Milliseconds()
+		if err != nil {
+			logger.Error(err, "operation failed", DurationMillisKey, duration)
+			return
+		}
+		logger.Info("operation completed", DurationMillisKey, duration)
+	}
+}
+
+// Timer starts a timer and returns a function that logs msg and keysAndValues
+// with the root logger and the time since Timer was called in milliseconds
+// under ElapsedMillisKey. The function can be called any number of times,
+// e.g. to log the time of each step since the start.
+//
+//	logged := log.Timer()
+//	loadConfig()
+//	logged("config loaded", "path", path)
+func Timer() func(msg string, keysAndValues ...interface{}) {
+	start := time.Now()
+	return func(msg string, keysAndValues ...interface{}) {
+		elapsed := time.Since(start).Milliseconds()
+		Info(msg, append(append([]interface{}{}, keysAndValues...), ElapsedMillisKey, elapsed)...)
+	}
+}
@@ -0,0 +1,62 @@
+package log_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOperation(t *testing.T) {
+	obs, logger := NewObservedLogger()
+	log.UseLogger(logger)
+
+	ll, done := log.Operation("sync")
+	ll.Info("syncing")
+	time.Sleep(20 * time.Millisecond)
+	done(nil)
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 2)
+	require.Equal(t, "sync", logs[0].Context[log.OperationKey])
+
+	completed := logs[1]
+	require.Equal(t, "operation completed", completed.Message)
+	require.Equal(t, "sync", completed.Context[log.OperationKey])
+	require.GreaterOrEqual(t, completed.Context[log.DurationMillisKey], int64(20))
+}
+
+func TestOperation_Error(t *testing.T) {
+	obs, logger := NewObservedLogger()
+	log.UseLogger(logger)
+
+	_, done := log.Operation("sync")
+	done(errors.New("fail boat"))
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 1)
+	require.Equal(t, "operation failed", logs[0].Message)
+	require.EqualError(t, logs[0].Error, "fail boat")
+	require.Contains(t, logs[0].Context, log.DurationMillisKey)
+}
+
+func TestTimer(t *testing.T) {
+	obs, logger := NewObservedLogger()
+	log.UseLogger(logger)
+
+	logged := log.Timer()
+	time.Sleep(20 * time.Millisecond)
+	logged("step done", "step", 1)
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 1)
+	require.Equal(t, "step done", logs[0].Message)
+	require.EqualValues(t, 1, logs[0].Context["step"])
+
+	elapsed, ok := logs[0].Context[log.ElapsedMillisKey].(int64)
+	require.True(t, ok, "expected elapsed_ms to be an int64")
+	require.GreaterOrEqual(t, elapsed, int64(20))
+	require.Less(t, elapsed, int64(5000))
+}
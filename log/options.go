@@ -1,7 +1,15 @@
 package log
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"io"
+	"os"
+	"reflect"
+	"time"
+
+	"github.com/ViaQ/logerr/kverrors"
 )
 
 // Option is a configuration option
@@ -14,9 +22,789 @@ func WithOutput(w io.Writer) Option {
 	}
 }
 
-// WithLogLevel sets the output log level and controls which verbosity logs are printed
+// WithEncoder encodes entries with e instead of the JSONEncoder. Options
+// configuring the JSONEncoder have no effect after WithEncoder
+func WithEncoder(e Encoder) Option {
+	return func(l *Logger) {
+		l.encoder = e
+		l.updateReserved()
+	}
+}
+
+// WithLineTerminator writes s instead of the newline after each entry, e.g.
+// "\r\n" for Windows tooling or "" for no terminator at all. It applies to all
+// encoders that write a line per entry, that is all builtin encoders except
+// the JournaldEncoder.
+func WithLineTerminator(s string) Option {
+	return func(l *Logger) {
+		l.opts.lineTerminator = &s
+	}
+}
+
+// WithSanitizeUTF8 replaces invalid UTF-8 in the encoded entries with the
+// replacement character U+FFFD so that raw bytes logged as strings can't
+// produce output that parsers reject. It is enabled by default and, like
+// WithLineTerminator, doesn't apply to the JournaldEncoder.
+func WithSanitizeUTF8(enabled bool) Option {
+	return func(l *Logger) {
+		l.opts.keepInvalidUTF8 = !enabled
+	}
+}
+
+// WithLogLevel sets the output log level and controls which verbosity logs are printed.
+// The level is set like SetLogLevel does once InitE validated all options, so
+// it is left unchanged if they are invalid.
 func WithLogLevel(v int) Option {
-	return func(*Logger) {
-		logLevel = v
+	return func(l *Logger) {
+		l.opts.logLevel = &v
+	}
+}
+
+// WithLevelCase writes the level of entries with a named severity, builtin or
+// registered with RegisterLevel, in uppercase if upper is set and in
+// lowercase otherwise, which is the default. Numeric levels are not affected.
+func WithLevelCase(upper bool) Option {
+	return func(l *Logger) {
+		l.opts.upperLevels = upper
+	}
+}
+
+// WithFlushOnError flushes the batched output as soon as an entry is logged
+// with Error so that the context of an error survives a subsequent crash
+// while other entries stay batched. See WithFlushInterval and WithFlushBytes
+func WithFlushOnError(enabled bool) Option {
+	return func(l *Logger) {
+		l.opts.flushOnError = enabled
+	}
+}
+
+// ErrorLevel is the level of entries logged with Error. See
+// WithEncoderForLevel
+const ErrorLevel = -1
+
+// WithEncoderForLevel encodes entries with the encoder of their level
+// instead of the encoder of the logger, e.g. to write errors in a format for
+// humans and other entries as JSON to the same output. Levels are
+// verbosities, and ErrorLevel matches entries logged with Error at any
+// verbosity. Entries without an encoder for their level use the encoder of
+// the logger. Fanout targets keep their own encoder.
+func WithEncoderForLevel(encoders map[int]Encoder) Option {
+	return func(l *Logger) {
+		m := make(map[int]Encoder, len(l.opts.levelEncoders)+len(encoders))
+		for k, v := range l.opts.levelEncoders {
+			m[k] = v
+		}
+		for k, v := range encoders {
+			m[k] = v
+		}
+		l.opts.levelEncoders = m
+	}
+}
+
+// WithOutputForLevel writes entries to the writer of their level instead of
+// the output of the logger, e.g. to rotate the file of errors separately.
+// Levels are matched like in WithEncoderForLevel, so ErrorLevel matches
+// entries logged with Error at any verbosity, and entries without a writer
+// for their level go to the output of the logger. The writers are written
+// to directly, without the batching of WithFlushInterval and WithFlushBytes,
+// and a failing writer doesn't affect the others.
+func WithOutputForLevel(outputs map[int]io.Writer) Option {
+	return func(l *Logger) {
+		m := make(map[int]io.Writer, len(l.opts.levelOutputs)+len(outputs))
+		for k, v := range l.opts.levelOutputs {
+			m[k] = v
+		}
+		for k, v := range outputs {
+			m[k] = v
+		}
+		l.opts.levelOutputs = m
+	}
+}
+
+// WithRuntimeTrace logs the message of every entry to the execution tracer
+// while it is running so that entries show up on the timeline of
+// runtime/trace. The component is used as the category. Loggers returned by
+// FromContext log to the trace task of their context.
+func WithRuntimeTrace(enabled bool) Option {
+	return func(l *Logger) {
+		l.opts.runtimeTrace = enabled
+	}
+}
+
+// WithCollapseRepeats suppresses consecutive entries with the same level,
+// component, message and field keys that are logged within window of the
+// first one. When a different entry is logged, the window elapses or the
+// logger is closed, the last suppressed entry is written with the number of
+// suppressed entries under RepeatedKey. A non-positive window disables it.
+func WithCollapseRepeats(window time.Duration) Option {
+	return func(l *Logger) {
+		if window <= 0 {
+			l.opts.collapser = nil
+			return
+		}
+		l.opts.collapser = &collapser{window: window}
+	}
+}
+
+// WithRateLimit caps the entries of the logger and the loggers derived from
+// it to perSecond on average with bursts of up to burst entries. Entries
+// above the limit are dropped and counted, see Stats and
+// WithRateLimitSummary. A burst below 1 allows bursts of perSecond entries.
+// Entries of the audit logger are never dropped. A perSecond of 0 disables
+// the rate limit.
+func WithRateLimit(perSecond, burst int) Option {
+	return func(l *Logger) {
+		if perSecond == 0 {
+			l.opts.rateLimiter = nil
+			return
+		}
+		if burst < 1 {
+			burst = perSecond
+		}
+		l.opts.rateLimiter = newRateLimiter(perSecond, burst)
+	}
+}
+
+// WithRateLimitSummary logs the number of entries dropped by the rate limit
+// under RateLimitedKey before the first entry that is allowed again. See
+// WithRateLimit
+func WithRateLimitSummary(enabled bool) Option {
+	return func(l *Logger) {
+		l.opts.rateLimitSummary = enabled
+	}
+}
+
+// WithNumbersAsStrings writes numbers in fields as strings so that consumers
+// that parse JSON numbers as float64, like JavaScript, don't lose the precision
+// of integers above 2^53. Only top level values are quoted. Builtin fields are
+// configured with WithBuiltinNumbersAsStrings
+func WithNumbersAsStrings(enabled bool) Option {
+	return func(l *Logger) {
+		l.opts.numbersAsStrings = enabled
+	}
+}
+
+// WithBuiltinNumbersAsStrings writes the numbers of builtin fields, like
+// SequenceKey or the key set with WithLevelNumeric, as strings. See
+// WithNumbersAsStrings
+func WithBuiltinNumbersAsStrings(enabled bool) Option {
+	return func(l *Logger) {
+		l.opts.builtinNumbersAsStrings = enabled
+	}
+}
+
+// Keys of the fields added by WithVersion
+const (
+	VersionKey = "version"
+	CommitKey  = "commit"
+)
+
+// WithVersion adds version and commit, usually set with -ldflags at build
+// time, under VersionKey and CommitKey to every entry. Empty values are read
+// from the build info of the binary if available: the module version and,
+// when built with Go 1.18 or later, the VCS revision.
+func WithVersion(version, commit string) Option {
+	return func(l *Logger) {
+		if version == "" || commit == "" {
+			v, c := buildVersion()
+			if version == "" {
+				version = v
+			}
+			if commit == "" {
+				commit = c
+			}
+		}
+		var keysAndValues []interface{}
+		if version != "" {
+			keysAndValues = append(keysAndValues, VersionKey, version)
+		}
+		if commit != "" {
+			keysAndValues = append(keysAndValues, CommitKey, commit)
+		}
+		l.context = combine(l.context, keysAndValues...)
+	}
+}
+
+// SchemaVersionKey is the key of the field added by WithSchemaVersion
+const SchemaVersionKey = "schema_version"
+
+// WithSchemaVersion adds v under SchemaVersionKey to every entry so that
+// consumers can tell which version of the log schema produced it. An empty
+// v adds nothing, which is the default.
+func WithSchemaVersion(v string) Option {
+	return func(l *Logger) {
+		if v != "" {
+			l.context = combine(l.context, SchemaVersionKey, v)
+		}
+	}
+}
+
+// WithMirrorErrorsToStderr writes entries logged with an error to os.Stderr
+// in addition to the output, e.g. so that they survive in the previous logs of
+// a crashed container while all entries go to stdout. Entries are not
+// mirrored if the output is os.Stderr already.
+func WithMirrorErrorsToStderr(enabled bool) Option {
+	return func(l *Logger) {
+		l.opts.errorMirror = nil
+		if enabled {
+			l.opts.errorMirror = os.Stderr
+		}
+	}
+}
+
+// WithAuditOutput writes the entries of the audit logger to w instead of the
+// output. See AuditLogger
+func WithAuditOutput(w io.Writer) Option {
+	return func(l *Logger) {
+		l.opts.auditOutput = w
+	}
+}
+
+// WithFlushInterval batches the output and flushes it every d. Use Close to
+// flush the last partial batch before exiting.
+func WithFlushInterval(d time.Duration) Option {
+	return func(l *Logger) {
+		l.batcher().setInterval(d)
+	}
+}
+
+// WithFlushBytes batches the output and flushes it as soon as n bytes are
+// buffered. Use Close to flush the last partial batch before exiting.
+func WithFlushBytes(n int) Option {
+	return func(l *Logger) {
+		l.batcher().setMaxBytes(n)
+	}
+}
+
+// WithStackTrace adds the stack captured by kverrors to entries with a logged
+// error if the logger uses the JSONEncoder. See kverrors.CaptureStacks
+func WithStackTrace(enabled bool) Option {
+	return func(l *Logger) {
+		l.updateJSONEncoder(func(enc *JSONEncoder) {
+			enc.StackTrace = enabled
+		})
+	}
+}
+
+// WithErrorChain lists the errors in the chain of a logged error under
+// ErrorChainKey if the logger uses the JSONEncoder
+func WithErrorChain(enabled bool) Option {
+	return func(l *Logger) {
+		l.updateJSONEncoder(func(enc *JSONEncoder) {
+			enc.ErrorChain = enabled
+		})
+	}
+}
+
+// WithHTMLEscape escapes <, > and & in strings if the logger uses the
+// JSONEncoder. It is disabled by default
+func WithHTMLEscape(enabled bool) Option {
+	return func(l *Logger) {
+		l.updateJSONEncoder(func(enc *JSONEncoder) {
+			enc.EscapeHTML = enabled
+		})
+	}
+}
+
+// WithComponentKey writes the component under key instead of ComponentKey if
+// the logger uses the JSONEncoder
+func WithComponentKey(key string) Option {
+	return func(l *Logger) {
+		l.updateJSONEncoder(func(enc *JSONEncoder) {
+			enc.ComponentKey = key
+		})
+	}
+}
+
+// WithEntrySize adds the size of every entry in bytes, including the field
+// itself and the trailing newline, under EntrySizeKey if the logger uses the
+// JSONEncoder. See JSONEncoder.EntrySize
+func WithEntrySize(enabled bool) Option {
+	return func(l *Logger) {
+		l.updateJSONEncoder(func(enc *JSONEncoder) {
+			enc.EntrySize = enabled
+		})
+	}
+}
+
+// WithPromotedKeys writes the fields with keys first, in the given order, if
+// the logger uses the JSONEncoder. This gives tools that match on the position
+// of fields, like CloudWatch metric filters, a predictable layout. Keys that
+// an entry doesn't have are left out, and the fields that are not promoted
+// keep their usual order with the remaining keys sorted. See
+// JSONEncoder.PromotedKeys
+func WithPromotedKeys(keys ...string) Option {
+	return func(l *Logger) {
+		l.updateJSONEncoder(func(enc *JSONEncoder) {
+			enc.PromotedKeys = append([]string(nil), keys...)
+		})
+	}
+}
+
+// WithFieldOrder writes the fields with keys first, in the given order, if
+// the logger uses the JSONEncoder, and all other fields after them sorted by
+// key. Unlike WithPromotedKeys it also places the builtin fields, e.g.
+// WithFieldOrder(log.TimeStampKey, "request_id", log.MessageKey), for
+// consumers that need an exact layout. It takes precedence over
+// WithPromotedKeys. See JSONEncoder.FieldOrder
+func WithFieldOrder(keys ...string) Option {
+	return func(l *Logger) {
+		l.updateJSONEncoder(func(enc *JSONEncoder) {
+			enc.FieldOrder = append([]string(nil), keys...)
+		})
+	}
+}
+
+// Keys of the object the component is written in by WithServiceObject
+const (
+	ServiceKey     = "_service"
+	ServiceNameKey = "name"
+)
+
+// WithServiceObject writes the component as {"_service":{"name":"..."}}
+// instead of a flat field if the logger uses the JSONEncoder, matching the
+// resource model of OpenTelemetry. The version and commit added by
+// WithVersion are nested in the object too. See JSONEncoder.ServiceObject
+func WithServiceObject(enabled bool) Option {
+	return func(l *Logger) {
+		l.updateJSONEncoder(func(enc *JSONEncoder) {
+			enc.ServiceObject = enabled
+		})
+	}
+}
+
+// updateJSONEncoder calls fn with the encoder of l if it is a JSONEncoder
+func (l *Logger) updateJSONEncoder(fn func(enc *JSONEncoder)) {
+	if enc, ok := l.encoder.(JSONEncoder); ok {
+		fn(&enc)
+		l.encoder = enc
+		l.updateReserved()
+	}
+}
+
+// WithSequenceNumbers adds a sequence number to every entry that increases by
+// one with each entry written by the logger and the loggers derived from it.
+// This allows detecting dropped or reordered entries downstream.
+func WithSequenceNumbers(enabled bool) Option {
+	return func(l *Logger) {
+		l.opts.seq = nil
+		if enabled {
+			l.opts.seq = new(uint64)
+		}
+	}
+}
+
+// WithTimestampPrecision truncates the time of every entry to a multiple of
+// d, e.g. time.Millisecond or time.Second, before it is formatted, which also
+// applies to the fields of WithTimePartitionFields. The timestamp is then
+// always formatted from the time of the entry rather than by TimestampFunc.
+// A precision of 0 keeps the full precision.
+func WithTimestampPrecision(d time.Duration) Option {
+	return func(l *Logger) {
+		l.opts.timestampPrecision = d
+	}
+}
+
+// WithTimePartitionFields adds the date, formatted as YYYY-MM-DD, under
+// DateKey and the hour under HourKey to every entry for partitioning entries
+// in log stores. Both are derived from the time of the entry in UTC like the
+// timestamp and from the same instant. If TimestampFunc is replaced they are
+// derived from the time it returns when that is an RFC 3339 timestamp.
+func WithTimePartitionFields(enabled bool) Option {
+	return func(l *Logger) {
+		l.opts.timePartitionFields = enabled
+	}
+}
+
+// WithGoroutineID adds the id of the goroutine that logged an entry under
+// GoroutineIDKey to help correlating interleaved entries. Go does not expose
+// goroutine ids, so the id is parsed from a stack trace taken for every entry,
+// which adds noticeable overhead. Only enable it while debugging.
+func WithGoroutineID(enabled bool) Option {
+	return func(l *Logger) {
+		l.opts.goroutineID = enabled
+	}
+}
+
+// WithMaxFields limits the number of fields of an entry to n to guard against
+// runaway entries. Builtin fields don't count against the limit. If an entry
+// has more fields, only the first n in sorted order are kept and
+// FieldsTruncatedKey is set. A limit of 0 disables it.
+func WithMaxFields(n int) Option {
+	return func(l *Logger) {
+		l.opts.maxFields = n
+	}
+}
+
+// WithMaxArrayElements limits slices and arrays, including those nested in
+// maps, to their first n elements so that a huge slice can't dominate an
+// entry. Longer ones are logged with their first n elements followed by the
+// marker "...(+k more)" for the k remaining elements. Byte slices are not
+// affected. A limit of 0 disables it.
+func WithMaxArrayElements(n int) Option {
+	return func(l *Logger) {
+		l.opts.maxArrayElements = n
+	}
+}
+
+// WithFloatNotation formats floats, including those in nested maps, slices and
+// arrays but not in structs, with the strconv.FormatFloat format notation,
+// one of 'f' for decimal, 'e' for scientific and 'g' for the shorter of both,
+// using the fewest digits that represent them exactly. E.g. 'f' logs 1e-7 as
+// 0.0000001 for parsers that reject exponents. WithNumbersAsStrings quotes
+// floats in the same notation. By default floats are formatted by
+// encoding/json.
+func WithFloatNotation(notation byte) Option {
+	return func(l *Logger) {
+		l.opts.floatNotation = notation
+	}
+}
+
+// WithFieldNamespace nests all fields that are not builtin fields under key,
+// e.g. {"_message":"hello","fields":{"city":"Athens"}}
+func WithFieldNamespace(key string) Option {
+	return func(l *Logger) {
+		l.opts.fieldNamespace = key
+		l.updateReserved()
+	}
+}
+
+// WithDefaultFields adds keysAndValues to every entry that doesn't already
+// set the keys through WithValues or the key/value pairs of the call.
+//
+// The precedence of fields is: WithFixedFields > per call and WithValues >
+// WithDefaultFields
+func WithDefaultFields(keysAndValues ...interface{}) Option {
+	return func(l *Logger) {
+		l.opts.defaultFields = mergeFields(l.opts.defaultFields, keysAndValues...)
+	}
+}
+
+// WithFixedFields adds keysAndValues to every entry, overriding any value set
+// for the keys through WithValues or the key/value pairs of the call.
+//
+// The precedence of fields is: WithFixedFields > per call and WithValues >
+// WithDefaultFields
+func WithFixedFields(keysAndValues ...interface{}) Option {
+	return func(l *Logger) {
+		l.opts.fixedFields = mergeFields(l.opts.fixedFields, keysAndValues...)
+	}
+}
+
+// WithErrorKey logs errors under key instead of ErrorKey
+func WithErrorKey(key string) Option {
+	return func(l *Logger) {
+		l.opts.errorKey = key
+		l.updateReserved()
+	}
+}
+
+// WithLevelNumeric adds the verbosity of every entry as a number under key in
+// addition to the level. For named severities this is the verbosity they
+// are registered with. See RegisterLevel
+func WithLevelNumeric(key string) Option {
+	return func(l *Logger) {
+		l.opts.levelNumericKey = key
+		l.updateReserved()
+	}
+}
+
+// WithLocalTimestamp adds the time of every entry in the local time zone,
+// formatted like the timestamp, under key in addition to the timestamp in UTC,
+// e.g. for people reading the logs directly. Both are the same instant. See
+// WithTimestampPrecision
+func WithLocalTimestamp(key string) Option {
+	return func(l *Logger) {
+		l.opts.localTimestampKey = key
+		l.updateReserved()
+	}
+}
+
+// WithPanicHandler calls fn with the value recovered from a panic while
+// encoding an entry, e.g. from a MarshalJSON method, instead of writing an
+// entry describing the panic
+func WithPanicHandler(fn func(recovered interface{})) Option {
+	return func(l *Logger) {
+		l.opts.panicHandler = fn
+	}
+}
+
+// ValueFormatter returns the value to encode in place of v and true, or false
+// if it does not handle v
+type ValueFormatter func(v interface{}) (interface{}, bool)
+
+// WithValueFormatter formats the values of the fields of every entry with fn
+// before they are encoded. Formatters are tried in the order they were added
+// and the first one that handles a value wins. Only top level values are
+// formatted, values nested in maps or structs are encoded as they are.
+func WithValueFormatter(fn ValueFormatter) Option {
+	return func(l *Logger) {
+		l.opts.valueFormatters = append(append([]ValueFormatter{}, l.opts.valueFormatters...), fn)
+	}
+}
+
+// WithTypeFormatter formats the values of type t with fn before they are
+// encoded, e.g. to render a type of another package that has no MarshalJSON.
+// It is a ValueFormatter that handles exactly the values of type t, so
+// pointers to t are not formatted unless registered as well. See
+// WithValueFormatter
+func WithTypeFormatter(t reflect.Type, fn func(v interface{}) interface{}) Option {
+	return WithValueFormatter(func(v interface{}) (interface{}, bool) {
+		if v == nil || reflect.TypeOf(v) != t {
+			return nil, false
+		}
+		return fn(v), true
+	})
+}
+
+// FieldReplacer returns the key and value to encode in place of a field and
+// true, or false to drop the field
+type FieldReplacer func(key string, value interface{}) (string, interface{}, bool)
+
+// WithReplaceField calls fn with the key and value of every field, including
+// builtin fields such as SequenceKey or the error, right before the entry is
+// encoded. The field is replaced by the returned key and value, or dropped if
+// fn returns false, which allows redacting, renaming and dropping fields in
+// one place. Fields are passed in sorted key order and a renamed field
+// replaces any field that has the new key. The timestamp, level, component
+// and message are not fields and can't be replaced. Functions added by
+// multiple options run in the order they were added.
+func WithReplaceField(fn FieldReplacer) Option {
+	return func(l *Logger) {
+		l.opts.replaceFields = append(append([]FieldReplacer{}, l.opts.replaceFields...), fn)
+	}
+}
+
+// WithDropKeys drops the fields with keys from every entry, whether they were
+// logged, added with WithValues or by an option, e.g. noisy fields of
+// libraries. It is applied like WithReplaceField, in the order the options
+// were added.
+func WithDropKeys(keys ...string) Option {
+	drop := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		drop[k] = true
+	}
+	return WithReplaceField(func(key string, value interface{}) (string, interface{}, bool) {
+		return key, value, !drop[key]
+	})
+}
+
+// WithAllowedKeys drops all fields other than those with keys and the builtin
+// fields, so that only approved fields can ever be logged. Fields added by
+// options, such as the version of WithVersion, must be allowed too. The
+// allowlist is applied last, after WithReplaceField and the options built on
+// it like WithDropKeys and WithHashedKeys, so it sees the final keys. Multiple
+// options add to the allowlist.
+func WithAllowedKeys(keys ...string) Option {
+	return func(l *Logger) {
+		allowed := make(map[string]bool, len(l.opts.allowedKeys)+len(keys))
+		for k := range l.opts.allowedKeys {
+			allowed[k] = true
+		}
+		for _, k := range keys {
+			allowed[k] = true
+		}
+		l.opts.allowedKeys = allowed
+	}
+}
+
+// WithHashedKeys replaces the values of the fields with the given keys by
+// the first 16 hex digits of the SHA-256 of salt followed by the value, so
+// that entries can be correlated on values like user ids without logging
+// them. Values that are not strings are hashed as formatted by fmt.Sprint.
+// The hash of a value is stable for the same salt. It is applied like
+// WithReplaceField, in the order the options were added.
+func WithHashedKeys(salt string, keys ...string) Option {
+	hashed := make(map[string]bool, len(keys))
+	for _, k := range keys {
+		hashed[k] = true
+	}
+	return WithReplaceField(func(key string, value interface{}) (string, interface{}, bool) {
+		if !hashed[key] {
+			return key, value, true
+		}
+		return key, hashValue(salt, value), true
+	})
+}
+
+// hashValue returns the salted, truncated hash of value. See WithHashedKeys
+func hashValue(salt string, value interface{}) string {
+	s, ok := value.(string)
+	if !ok {
+		s = fmt.Sprint(value)
+	}
+	sum := sha256.Sum256([]byte(salt + s))
+	return hex.EncodeToString(sum[:8])
+}
+
+// WithStrictKeys validates all key/value pairs. Non-string keys, an odd
+// number of arguments and keys that collide with builtin fields are listed
+// under StrictKeysKey rather than silently coerced or dropped. This is meant
+// for tests and CI rather than production.
+func WithStrictKeys(enabled bool) Option {
+	return func(l *Logger) {
+		l.opts.strictKeys = enabled
+	}
+}
+
+// WithStrictKeysHandler calls fn with each violation found if strict keys are
+// enabled. See WithStrictKeys
+func WithStrictKeysHandler(fn func(violation string)) Option {
+	return func(l *Logger) {
+		l.opts.strictKeysHandler = fn
+	}
+}
+
+// Target is an output and the encoder used to write entries to it
+type Target struct {
+	Encoder Encoder
+	Output  io.Writer
+}
+
+// WithFanout writes every entry to each of targets in addition to the output
+// of the logger, e.g. to write JSON and another format at the same time. The
+// targets are independent: an encoding failure for one of them does not
+// affect the others.
+func WithFanout(targets ...Target) Option {
+	return func(l *Logger) {
+		l.opts.fanout = append(append([]Target{}, l.opts.fanout...), targets...)
+	}
+}
+
+// reservedKey is the name of a builtin field and the key it is written as
+type reservedKey struct {
+	field string
+	key   string
+}
+
+// reservedKeys returns the keys of the builtin fields written by l
+func (l *Logger) reservedKeys() []reservedKey {
+	keys := []reservedKey{
+		{field: "timestamp", key: TimeStampKey},
+		{field: "file_line", key: FileLineKey},
+		{field: "level", key: LevelKey},
+		{field: "message", key: MessageKey},
+		{field: "stacktrace", key: StackTraceKey},
+		{field: "error_chain", key: ErrorChainKey},
+		{field: "sequence", key: SequenceKey},
+		{field: "date", key: DateKey},
+		{field: "hour", key: HourKey},
+		{field: "goroutine_id", key: GoroutineIDKey},
+		{field: "invalid_json", key: InvalidJSONKey},
+		{field: "panic", key: PanicKey},
+		{field: "repeated", key: RepeatedKey},
+		{field: "strict_keys", key: StrictKeysKey},
+		{field: "fields_truncated", key: FieldsTruncatedKey},
+		{field: "entry_size", key: EntrySizeKey},
+		{field: "rate_limited", key: RateLimitedKey},
+		{field: "throttled", key: ThrottledKey},
+		{field: "labels", key: LabelsKey},
+		{field: "context_reason", key: ContextReasonKey},
+		{field: "context_cause", key: ContextCauseKey},
+		// configurable keys are last so that collisions are reported
+		// against the option that set them
+		{field: "error", key: l.errorKey()},
+		{field: "component", key: l.componentKey()},
+	}
+	if l.opts.levelNumericKey != "" {
+		keys = append(keys, reservedKey{field: "level_numeric", key: l.opts.levelNumericKey})
+	}
+	if l.opts.localTimestampKey != "" {
+		keys = append(keys, reservedKey{field: "local_timestamp", key: l.opts.localTimestampKey})
+	}
+	if l.opts.fieldNamespace != "" {
+		keys = append(keys, reservedKey{field: "field_namespace", key: l.opts.fieldNamespace})
+	}
+	if enc, ok := l.encoder.(JSONEncoder); ok && enc.ServiceObject {
+		keys = append(keys, reservedKey{field: "service", key: ServiceKey})
+	}
+	return keys
+}
+
+// updateReserved replaces the set of reserved keys looked up by isReserved.
+// Options that change any of the reservedKeys must call it.
+func (l *Logger) updateReserved() {
+	keys := l.reservedKeys()
+	set := make(map[string]struct{}, len(keys))
+	for _, rk := range keys {
+		set[rk.key] = struct{}{}
+	}
+	l.opts.reserved = set
+}
+
+// validate returns an error wrapping ErrInvalidOption if the options applied
+// to l are invalid or conflict with each other
+func (l *Logger) validate() error {
+	l.mtx.RLock()
+	defer l.mtx.RUnlock()
+
+	output := l.output
+	if bw, ok := output.(*batchWriter); ok {
+		bw.mtx.Lock()
+		output = bw.w
+		interval, maxBytes := bw.interval, bw.maxBytes
+		bw.mtx.Unlock()
+
+		if interval < 0 {
+			return kverrors.Add(ErrInvalidOption, "option", "flush_interval", "reason", "must not be negative")
+		}
+		if maxBytes < 0 {
+			return kverrors.Add(ErrInvalidOption, "option", "flush_bytes", "reason", "must not be negative")
+		}
+	}
+	if r := l.opts.rateLimiter; r != nil && r.rate < 0 {
+		return kverrors.Add(ErrInvalidOption, "option", "rate_limit", "reason", "must not be negative")
+	}
+	if l.opts.maxFields < 0 {
+		return kverrors.Add(ErrInvalidOption, "option", "max_fields", "reason", "must not be negative")
+	}
+	switch l.opts.floatNotation {
+	case 0, 'f', 'e', 'g':
+	default:
+		return kverrors.Add(ErrInvalidOption, "option", "float_notation", "reason", "must be one of 'f', 'e' or 'g'")
+	}
+	if l.opts.timestampPrecision < 0 {
+		return kverrors.Add(ErrInvalidOption, "option", "timestamp_precision", "reason", "must not be negative")
+	}
+	if l.opts.maxArrayElements < 0 {
+		return kverrors.Add(ErrInvalidOption, "option", "max_array_elements", "reason", "must not be negative")
+	}
+	if output == nil {
+		return kverrors.Add(ErrInvalidOption, "option", "output", "reason", "must not be nil")
+	}
+	if l.encoder == nil {
+		return kverrors.Add(ErrInvalidOption, "option", "encoder", "reason", "must not be nil")
+	}
+	for level, enc := range l.opts.levelEncoders {
+		if enc == nil {
+			return kverrors.Add(ErrInvalidOption, "option", "encoder_for_level", "reason", "must not be nil", "level", level)
+		}
+	}
+	for level, w := range l.opts.levelOutputs {
+		if w == nil {
+			return kverrors.Add(ErrInvalidOption, "option", "output_for_level", "reason", "must not be nil", "level", level)
+		}
+	}
+	for i, t := range l.opts.fanout {
+		if t.Encoder == nil || t.Output == nil {
+			return kverrors.Add(ErrInvalidOption, "option", "fanout", "reason", "encoder and output must not be nil", "index", i)
+		}
+	}
+
+	seen := map[string]string{}
+	for _, rk := range l.reservedKeys() {
+		if rk.key == "" {
+			return kverrors.Add(ErrInvalidOption, "option", rk.field+"_key", "reason", "must not be empty")
+		}
+		if other, ok := seen[rk.key]; ok {
+			return kverrors.Add(ErrInvalidOption,
+				"option", rk.field+"_key",
+				"reason", "collides with another builtin field",
+				"key", rk.key,
+				"field", other,
+			)
+		}
+		seen[rk.key] = rk.field
 	}
+	return nil
 }
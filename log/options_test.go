@@ -0,0 +1,959 @@
+package log_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/ViaQ/logerr/kverrors"
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+// decodeEntry decodes a single JSON encoded entry
+func decodeEntry(t *testing.T, b []byte) map[string]interface{} {
+	m := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(b, &m), string(b))
+	return m
+}
+
+func TestWithFieldNamespace(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	log.WithFieldNamespace("fields")(logger)
+
+	logger.WithValues("city", "Athens").Error(kverrors.New("an error"), "hello, world",
+		"raw", json.RawMessage(`{`),
+	)
+
+	m := decodeEntry(t, buf.Bytes())
+	require.Equal(t, map[string]interface{}{
+		"city": "Athens",
+		"raw":  "{",
+	}, m["fields"])
+	require.Equal(t, "hello, world", m[log.MessageKey])
+	require.Equal(t, map[string]interface{}{"msg": "an error"}, m[log.ErrorKey])
+	require.Equal(t, []interface{}{"fields.raw"}, m[log.InvalidJSONKey])
+	require.NotContains(t, m, "city")
+}
+
+func TestWithFieldNamespace_CollidesWithBuiltinField(t *testing.T) {
+	err := log.InitE(t.Name(), []log.Option{log.WithFieldNamespace(log.MessageKey)})
+	require.Error(t, err)
+	require.EqualValues(t, "field_namespace_key", kverrors.KVs(err)["option"])
+}
+
+func TestWithDefaultFields_WithFixedFields_Precedence(t *testing.T) {
+	obs, logger := NewObservedLogger()
+	log.WithDefaultFields("env", "dev", "region", "eu", "zone", "a")(logger)
+	log.WithFixedFields("env", "prod")(logger)
+
+	logger.Info("defaults only")
+	logger.Info("per call", "env", "staging", "region", "us")
+	logger.WithValues("zone", "b").Info("with values")
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 3)
+	require.Equal(t, map[string]interface{}{"env": "prod", "region": "eu", "zone": "a"}, logs[0].Context)
+	require.Equal(t, map[string]interface{}{"env": "prod", "region": "us", "zone": "a"}, logs[1].Context)
+	require.Equal(t, map[string]interface{}{"env": "prod", "region": "eu", "zone": "b"}, logs[2].Context)
+}
+
+func TestWithErrorKey(t *testing.T) {
+	kverrors.CaptureStacks(true)
+	err := kverrors.New("an error")
+	kverrors.CaptureStacks(false)
+
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{StackTrace: true})
+	log.WithErrorKey("err")(logger)
+
+	logger.Error(err, "hello, world")
+
+	m := decodeEntry(t, buf.Bytes())
+	require.Equal(t, map[string]interface{}{"msg": "an error"}, m["err"])
+	require.NotContains(t, m, log.ErrorKey)
+	require.Contains(t, m, log.StackTraceKey)
+}
+
+func TestWithErrorKey_CollidesWithBuiltinField(t *testing.T) {
+	for _, key := range []string{log.MessageKey, log.StackTraceKey, ""} {
+		err := log.InitE(t.Name(), []log.Option{log.WithErrorKey(key)})
+		if key == "" {
+			require.NoError(t, err, "empty key should use the default")
+			continue
+		}
+		require.Error(t, err, key)
+		require.EqualValues(t, "error_key", kverrors.KVs(err)["option"], key)
+	}
+}
+
+func TestWithLevelNumeric(t *testing.T) {
+	log.SetLogLevel(1)
+	defer log.SetLogLevel(0)
+
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	log.WithLevelNumeric("_level_num")(logger)
+
+	for name, logFunc := range map[string]func(){
+		"info":  func() { logger.Info("hello, world") },
+		"debug": func() { logger.V(1).Info("hello, world") },
+		"error": func() { logger.Error(kverrors.New("an error"), "hello, world") },
+	} {
+		buf.Reset()
+		logFunc()
+
+		m := decodeEntry(t, buf.Bytes())
+		require.Contains(t, m, "_level_num", name)
+		require.Equal(t, fmt.Sprint(m["_level_num"]), m[log.LevelKey], name)
+	}
+}
+
+func TestWithStrictKeys(t *testing.T) {
+	for name, tc := range map[string]struct {
+		keysAndValues []interface{}
+		violation     string
+	}{
+		"odd number":     {[]interface{}{"key", "value", "missing"}, "odd number of arguments: 3"},
+		"non-string key": {[]interface{}{1, "value"}, "key at index 0 is not a string: int"},
+		"reserved key":   {[]interface{}{log.MessageKey, "value"}, `key "_message" collides with a builtin field`},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var reported []string
+
+			buf := bytes.NewBuffer(nil)
+			logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+			log.WithStrictKeys(true)(logger)
+			log.WithStrictKeysHandler(func(v string) {
+				reported = append(reported, v)
+			})(logger)
+
+			logger.Info("hello, world", tc.keysAndValues...)
+			require.Equal(t, []interface{}{tc.violation}, decodeEntry(t, buf.Bytes())[log.StrictKeysKey])
+			require.Equal(t, []string{tc.violation}, reported)
+
+			buf.Reset()
+			logger.WithValues(tc.keysAndValues...).Error(kverrors.New("an error"), "hello, world")
+			require.Equal(t, []interface{}{tc.violation}, decodeEntry(t, buf.Bytes())[log.StrictKeysKey])
+		})
+	}
+}
+
+func TestWithStrictKeys_DisabledByDefault(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	logger.Info("hello, world", "key", "value", "missing")
+
+	require.NotContains(t, decodeEntry(t, buf.Bytes()), log.StrictKeysKey)
+}
+
+func TestWithComponentKey(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("mycomponent", buf, 0, log.JSONEncoder{})
+	log.WithComponentKey("logger")(logger)
+
+	logger.Info("hello, world")
+
+	m := decodeEntry(t, buf.Bytes())
+	require.Equal(t, "mycomponent", m["logger"])
+	require.NotContains(t, m, log.ComponentKey)
+}
+
+func TestWithPromotedKeys(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("mycomponent", buf, 0, log.JSONEncoder{})
+	log.WithPromotedKeys("status", "path", "missing", "status")(logger)
+
+	logger.Info("request", "user", "alice", "path", "/healthz", "status", 200, "bytes", 12)
+
+	out := buf.String()
+	require.True(t, strings.HasPrefix(out, `{"status":200,"path":"/healthz","`+log.TimeStampKey+`":`), out)
+	require.NotContains(t, out, "missing")
+	require.Less(t, strings.Index(out, `"bytes"`), strings.Index(out, `"user"`), "expected the other keys to stay sorted")
+	require.Equal(t, 1, strings.Count(out, `"status"`))
+}
+
+func TestWithFieldOrder(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("mycomponent", buf, 0, log.JSONEncoder{})
+	log.WithPromotedKeys("user")(logger)
+	log.WithFieldOrder("status", log.MessageKey, "missing", "path", log.TimeStampKey)(logger)
+
+	logger.Info("request", "user", "alice", "path", "/healthz", "status", 200, "bytes", 12)
+
+	dec := json.NewDecoder(bytes.NewReader(buf.Bytes()))
+	var keys []string
+	_, err := dec.Token()
+	require.NoError(t, err)
+	for dec.More() {
+		tok, err := dec.Token()
+		require.NoError(t, err)
+		keys = append(keys, tok.(string))
+		var v interface{}
+		require.NoError(t, dec.Decode(&v))
+	}
+	require.Equal(t, []string{
+		"status", log.MessageKey, "path", log.TimeStampKey,
+		log.ComponentKey, log.LevelKey, "bytes", "user",
+	}, keys, buf.String())
+}
+
+func TestWithComponentKey_CollidesWithBuiltinField(t *testing.T) {
+	err := log.InitE(t.Name(), []log.Option{log.WithComponentKey(log.TimeStampKey)})
+	require.Error(t, err)
+	require.EqualValues(t, "component_key", kverrors.KVs(err)["option"])
+
+	err = log.InitE(t.Name(), []log.Option{log.WithComponentKey("name"), log.WithErrorKey("name")})
+	require.Error(t, err)
+	require.EqualValues(t, "component_key", kverrors.KVs(err)["option"])
+	require.EqualValues(t, "error", kverrors.KVs(err)["field"])
+}
+
+func TestWithFanout(t *testing.T) {
+	jsonBuf := bytes.NewBuffer(nil)
+	textBuf := bytes.NewBuffer(nil)
+	failingBuf := bytes.NewBuffer(nil)
+
+	text := fakeEncoder{
+		EncodeFunc: func(w io.Writer, entry interface{}) error {
+			e := entry.(log.Entry)
+			_, err := fmt.Fprintf(w, "msg=%q city=%v\n", e.Message, e.Fields["city"])
+			return err
+		},
+	}
+	failing := fakeEncoder{
+		EncodeFunc: func(io.Writer, interface{}) error {
+			return io.ErrShortWrite
+		},
+	}
+
+	logger := log.NewLogger("", jsonBuf, 0, log.JSONEncoder{})
+	log.WithFanout(
+		log.Target{Encoder: failing, Output: failingBuf},
+		log.Target{Encoder: text, Output: textBuf},
+	)(logger)
+
+	logger.Info("hello, world", "city", "Athens")
+
+	require.Equal(t, "Athens", decodeEntry(t, jsonBuf.Bytes())["city"])
+	require.Equal(t, "msg=\"hello, world\" city=Athens\n", textBuf.String())
+	require.Contains(t, failingBuf.String(), "failed to encode message")
+}
+
+func TestWithHTMLEscape(t *testing.T) {
+	const url = "https://example.com/search?q=<logs>&lang=en/γλώσσα"
+
+	buf := &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf)})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Info("hello", "url", url, log.String("link", url))
+	require.Contains(t, buf.String(), `"url":"`+url+`"`)
+	require.Contains(t, buf.String(), `"link":"`+url+`"`)
+	require.Equal(t, url, decodeEntry(t, buf.Bytes())["url"])
+
+	buf.Reset()
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf), log.WithHTMLEscape(true)})
+	log.Info("hello", "url", url)
+	require.Contains(t, buf.String(), `\u003clogs\u003e\u0026lang=en/γλώσσα`)
+	require.Equal(t, url, decodeEntry(t, buf.Bytes())["url"])
+}
+
+type secret string
+
+func TestWithValueFormatter(t *testing.T) {
+	redact := func(v interface{}) (interface{}, bool) {
+		if _, ok := v.(secret); ok {
+			return "[REDACTED]", true
+		}
+		return nil, false
+	}
+	unused := func(v interface{}) (interface{}, bool) {
+		if _, ok := v.(secret); ok {
+			return "unused", true
+		}
+		return nil, false
+	}
+
+	buf := &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithValueFormatter(redact),
+		log.WithValueFormatter(unused),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Info("hello", "password", secret("hunter2"), "user", "alice")
+
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, "[REDACTED]", entry["password"])
+	require.Equal(t, "alice", entry["user"])
+}
+
+func TestWithTypeFormatter(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithTypeFormatter(reflect.TypeOf(time.Duration(0)), func(v interface{}) interface{} {
+			return v.(time.Duration).String()
+		}),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Info("hello", "elapsed", 1500*time.Millisecond, "attempts", int64(3), log.Int("timeout", int(time.Second)))
+
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, "1.5s", entry["elapsed"])
+	require.EqualValues(t, 3, entry["attempts"])
+	require.EqualValues(t, time.Second, entry["timeout"])
+}
+
+func TestWithGoroutineID(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf), log.WithGoroutineID(true)})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	done := make(chan struct{})
+	log.Info("first")
+	go func() {
+		defer close(done)
+		log.Info("second")
+	}()
+	<-done
+
+	lines := bytes.Split(bytes.TrimSpace([]byte(buf.String())), []byte("\n"))
+	require.Len(t, lines, 2)
+	first, second := decodeEntry(t, lines[0])[log.GoroutineIDKey], decodeEntry(t, lines[1])[log.GoroutineIDKey]
+	require.NotZero(t, first)
+	require.NotZero(t, second)
+	require.NotEqual(t, first, second)
+}
+
+func TestWithMaxFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf), log.WithMaxFields(2)})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Error(io.EOF, "hello", "a", 1, "b", 2, "c", 3, "d", 4)
+
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, true, entry[log.FieldsTruncatedKey])
+	require.Contains(t, entry, "a")
+	require.Contains(t, entry, "b")
+	require.NotContains(t, entry, "c")
+	require.NotContains(t, entry, "d")
+	require.Contains(t, entry, log.ErrorKey)
+
+	buf.Reset()
+	log.Info("hello", "a", 1, "b", 2)
+	require.NotContains(t, decodeEntry(t, buf.Bytes()), log.FieldsTruncatedKey)
+}
+
+func TestWithMaxFields_Negative(t *testing.T) {
+	err := log.InitE(t.Name(), []log.Option{log.WithMaxFields(-1)})
+	require.Equal(t, log.ErrInvalidOption, kverrors.Root(err))
+}
+
+func TestWithEncoderForLevel(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithEncoderForLevel(map[int]log.Encoder{log.ErrorLevel: log.ConsoleEncoder{}}),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Info("compact")
+	log.Error(io.EOF, "pretty")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	require.Equal(t, "compact", decodeEntry(t, []byte(lines[0]))[log.MessageKey])
+	require.False(t, json.Valid([]byte(lines[1])))
+	require.Contains(t, lines[1], "\tpretty\t")
+}
+
+func TestWithEncoderForLevel_Verbosity(t *testing.T) {
+	defer log.SetLogLevel(0)
+
+	buf := &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithLogLevel(1),
+		log.WithEncoderForLevel(map[int]log.Encoder{1: log.LogfmtEncoder{}}),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.V(1).Info("debug")
+	require.True(t, strings.HasPrefix(buf.String(), log.TimeStampKey+"="))
+}
+
+func TestWithEncoderForLevel_NilEncoder(t *testing.T) {
+	err := log.InitE(t.Name(), []log.Option{log.WithEncoderForLevel(map[int]log.Encoder{0: nil})})
+	require.Equal(t, log.ErrInvalidOption, kverrors.Root(err))
+}
+
+func TestWithOutputForLevel(t *testing.T) {
+	defer log.SetLogLevel(0)
+
+	infoBuf, debugBuf, errBuf := &bytes.Buffer{}, &bytes.Buffer{}, &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(infoBuf),
+		log.WithLogLevel(2),
+		log.WithOutputForLevel(map[int]io.Writer{
+			1:              debugBuf,
+			2:              failingWriter{},
+			log.ErrorLevel: errBuf,
+		}),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Info("info")
+	log.V(1).Info("debug")
+	log.V(2).Info("lost")
+	log.V(1).Error(io.EOF, "error")
+	log.Info("still info")
+
+	require.Equal(t, 2, strings.Count(infoBuf.String(), "\n"))
+	require.Contains(t, infoBuf.String(), `"still info"`)
+	require.Equal(t, "debug", decodeEntry(t, debugBuf.Bytes())[log.MessageKey])
+	require.Equal(t, "error", decodeEntry(t, errBuf.Bytes())[log.MessageKey])
+}
+
+func TestWithOutputForLevel_NilOutput(t *testing.T) {
+	err := log.InitE(t.Name(), []log.Option{log.WithOutputForLevel(map[int]io.Writer{0: nil})})
+	require.Equal(t, log.ErrInvalidOption, kverrors.Root(err))
+}
+
+// failingWriter fails every write
+type failingWriter struct{}
+
+func (failingWriter) Write([]byte) (int, error) {
+	return 0, errors.New("disk full")
+}
+
+func TestWithCollapseRepeats(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf), log.WithCollapseRepeats(time.Hour)})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	for i := 0; i < 5; i++ {
+		log.Error(io.EOF, "retry failed", "attempt", i)
+	}
+	log.Info("gave up")
+	log.Info("gave up")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+
+	first, summary, next := decodeEntry(t, []byte(lines[0])), decodeEntry(t, []byte(lines[1])), decodeEntry(t, []byte(lines[2]))
+	require.Equal(t, "retry failed", first[log.MessageKey])
+	require.NotContains(t, first, log.RepeatedKey)
+	require.Equal(t, "retry failed", summary[log.MessageKey])
+	require.Equal(t, float64(4), summary[log.RepeatedKey])
+	require.Equal(t, float64(4), summary["attempt"])
+	require.Equal(t, "gave up", next[log.MessageKey])
+
+	require.NoError(t, log.Close())
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 4)
+	require.Equal(t, float64(1), decodeEntry(t, []byte(lines[3]))[log.RepeatedKey])
+}
+
+func TestWithCollapseRepeats_WindowElapses(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf), log.WithCollapseRepeats(20 * time.Millisecond)})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Info("hello")
+	log.Info("hello")
+	log.Info("hello")
+
+	require.Eventually(t, func() bool {
+		return strings.Count(buf.String(), "\n") == 2
+	}, time.Second, 5*time.Millisecond)
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Equal(t, float64(2), decodeEntry(t, []byte(lines[1]))[log.RepeatedKey])
+}
+
+func TestWithNumbersAsStrings(t *testing.T) {
+	const big int64 = 1<<53 + 1
+
+	buf := &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithSequenceNumbers(true),
+		log.WithNumbersAsStrings(true),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Info("hello", "id", big, "ratio", 0.5, log.Int("typed", 7), "name", "alice")
+	require.Contains(t, buf.String(), `"id":"9007199254740993"`)
+	require.Contains(t, buf.String(), `"ratio":"0.5"`)
+	require.Contains(t, buf.String(), `"typed":"7"`)
+	require.Contains(t, buf.String(), `"name":"alice"`)
+	require.Contains(t, buf.String(), `"_seq":0`)
+
+	buf.Reset()
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithSequenceNumbers(true),
+		log.WithBuiltinNumbersAsStrings(true),
+	})
+	log.Info("hello", "id", big)
+	require.Contains(t, buf.String(), `"id":9007199254740993`)
+	require.Contains(t, buf.String(), `"_seq":"0"`)
+}
+
+func TestWithTimePartitionFields(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf), log.WithTimePartitionFields(true)})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	at := time.Date(2021, 3, 4, 23, 30, 0, 0, time.FixedZone("UTC-2", -2*60*60))
+	log.InfoAt(at, "hello")
+
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, "2021-03-05T01:30:00Z", entry[log.TimeStampKey])
+	require.Equal(t, "2021-03-05", entry[log.DateKey])
+	require.Equal(t, float64(1), entry[log.HourKey])
+
+	defer func(f func() string) { log.TimestampFunc = f }(log.TimestampFunc)
+	log.TimestampFunc = func() string { return "2021-03-04T23:59:59.999Z" }
+
+	buf.Reset()
+	log.Info("hello")
+	entry = decodeEntry(t, buf.Bytes())
+	require.Equal(t, "2021-03-04", entry[log.DateKey])
+	require.Equal(t, float64(23), entry[log.HourKey])
+}
+
+func TestWithVersion(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf), log.WithVersion("v1.2.3", "abc123")})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.WithValues("user", "alice").Info("hello")
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, "v1.2.3", entry[log.VersionKey])
+	require.Equal(t, "abc123", entry[log.CommitKey])
+	require.Equal(t, "alice", entry["user"])
+}
+
+func TestWithMirrorErrorsToStderr(t *testing.T) {
+	stderr, err := ioutil.TempFile(t.TempDir(), "stderr")
+	require.NoError(t, err)
+	defer stderr.Close()
+
+	orig := os.Stderr
+	os.Stderr = stderr
+	defer func() { os.Stderr = orig }()
+
+	buf := &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf), log.WithMirrorErrorsToStderr(true)})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Info("all good")
+	log.Error(errors.New("fail boat"), "went wrong")
+
+	mirrored, err := ioutil.ReadFile(stderr.Name())
+	require.NoError(t, err)
+	require.Contains(t, buf.String(), "all good")
+	require.Contains(t, buf.String(), "went wrong")
+	require.NotContains(t, string(mirrored), "all good")
+	require.Contains(t, string(mirrored), "went wrong")
+	require.Equal(t, 1, strings.Count(string(mirrored), "\n"))
+}
+
+func TestWithReplaceField(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithSequenceNumbers(true),
+		log.WithReplaceField(func(key string, value interface{}) (string, interface{}, bool) {
+			switch key {
+			case "password", log.SequenceKey:
+				return key, nil, false
+			case "usr":
+				return "user", value, true
+			case "token":
+				return key, "[REDACTED]", true
+			}
+			return key, value, true
+		}),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Info("hello", "password", "hunter2", "usr", "alice", log.String("token", "abc"), "city", "Athens")
+
+	entry := decodeEntry(t, buf.Bytes())
+	require.NotContains(t, entry, "password")
+	require.NotContains(t, entry, log.SequenceKey)
+	require.NotContains(t, entry, "usr")
+	require.Equal(t, "alice", entry["user"])
+	require.Equal(t, "[REDACTED]", entry["token"])
+	require.Equal(t, "Athens", entry["city"])
+	require.Equal(t, "hello", entry[log.MessageKey])
+}
+
+func TestWithLineTerminator(t *testing.T) {
+	for _, enc := range []log.Encoder{log.JSONEncoder{}, log.LogfmtEncoder{}} {
+		for _, terminator := range []string{"\r\n", "\x1e"} {
+			buf := bytes.NewBuffer(nil)
+			logger := log.NewLogger("test", buf, 0, enc)
+			log.WithLineTerminator(terminator)(logger)
+
+			logger.Info("first")
+			logger.Info("second")
+
+			out := buf.String()
+			require.True(t, strings.HasSuffix(out, terminator), "%T: %q", enc, out)
+			entries := strings.Split(strings.TrimSuffix(out, terminator), terminator)
+			require.Len(t, entries, 2, "%T: %q", enc, out)
+			require.Contains(t, entries[0], "first")
+			require.Contains(t, entries[1], "second")
+			require.NotContains(t, strings.ReplaceAll(out, terminator, ""), "\n")
+		}
+
+		buf := bytes.NewBuffer(nil)
+		logger := log.NewLogger("test", buf, 0, enc)
+		log.WithLineTerminator("")(logger)
+		logger.Info("hello, world")
+		require.NotContains(t, buf.String(), "\n")
+		require.NotEmpty(t, buf.String())
+	}
+}
+
+// multiWriteEncoder encodes entries as two lines written separately
+type multiWriteEncoder struct{}
+
+func (multiWriteEncoder) Encode(w io.Writer, entry interface{}) error {
+	if _, err := w.Write([]byte(entry.(log.Entry).Message + "\n")); err != nil {
+		return err
+	}
+	_, err := w.Write([]byte("end\n"))
+	return err
+}
+
+func TestWithLineTerminator_MultipleWrites(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("test", buf, 0, multiWriteEncoder{})
+	log.WithLineTerminator("\x1e")(logger)
+
+	logger.Info("first")
+	logger.Info("second")
+	require.Equal(t, "first\nend\x1esecond\nend\x1e", buf.String())
+}
+
+func TestWithSanitizeUTF8(t *testing.T) {
+	invalid := "raw \xff\xfe bytes"
+
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("test", buf, 0, log.JSONEncoder{})
+	logger.Info(invalid, "str", invalid, "raw", json.RawMessage(`"`+invalid+`"`))
+
+	require.True(t, utf8.Valid(buf.Bytes()), buf.String())
+	require.True(t, json.Valid(buf.Bytes()), buf.String())
+	entry := decodeEntry(t, buf.Bytes())
+	for _, k := range []string{log.MessageKey, "str", "raw"} {
+		require.Contains(t, entry[k], string(utf8.RuneError))
+		require.NotContains(t, entry[k], "\xff")
+	}
+
+	buf.Reset()
+	log.WithSanitizeUTF8(false)(logger)
+	logger.Info("hello, world", "raw", json.RawMessage(`"`+invalid+`"`))
+	require.False(t, utf8.Valid(buf.Bytes()))
+	require.Contains(t, buf.String(), invalid)
+}
+
+// bytewiseEncoder writes the entries encoded by enc one byte at a time
+type bytewiseEncoder struct {
+	enc log.Encoder
+}
+
+func (b bytewiseEncoder) Encode(w io.Writer, entry interface{}) error {
+	buf := bytes.NewBuffer(nil)
+	if err := b.enc.Encode(buf, entry); err != nil {
+		return err
+	}
+	for _, c := range buf.Bytes() {
+		if _, err := w.Write([]byte{c}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func TestWithSanitizeUTF8_MultipleWrites(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("test", buf, 0, bytewiseEncoder{enc: log.JSONEncoder{}})
+	logger.Info("café", "raw", json.RawMessage("\"\xff\""))
+
+	require.True(t, utf8.Valid(buf.Bytes()), buf.String())
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, "café", entry[log.MessageKey])
+	require.Equal(t, string(utf8.RuneError), entry["raw"])
+}
+
+func TestWithMaxArrayElements(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	log.WithMaxArrayElements(3)(logger)
+
+	ids := make([]int, 10)
+	for i := range ids {
+		ids[i] = i
+	}
+	logger.Info("hello, world",
+		"ids", ids,
+		"short", []string{"a", "b"},
+		"nested", map[string]interface{}{"names": [5]string{"a", "b", "c", "d", "e"}},
+		"raw", []byte("not an array"),
+	)
+
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, []interface{}{0.0, 1.0, 2.0, "...(+7 more)"}, entry["ids"])
+	require.Equal(t, []interface{}{"a", "b"}, entry["short"])
+	require.Equal(t, map[string]interface{}{
+		"names": []interface{}{"a", "b", "c", "...(+2 more)"},
+	}, entry["nested"])
+	require.NotContains(t, fmt.Sprint(entry["raw"]), "more")
+}
+
+func TestWithHashedKeys(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	log.WithHashedKeys("pepper", "user_id", "account")(logger)
+
+	logger.Info("hello, world", "user_id", "alice", "account", 42, "city", "Athens")
+
+	hash := func(s string) string {
+		sum := sha256.Sum256([]byte("pepper" + s))
+		return hex.EncodeToString(sum[:])[:16]
+	}
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, hash("alice"), entry["user_id"])
+	require.Equal(t, hash("42"), entry["account"])
+	require.Equal(t, "Athens", entry["city"])
+	require.NotContains(t, buf.String(), "alice")
+
+	buf.Reset()
+	logger.Info("again", "user_id", "alice")
+	require.Equal(t, hash("alice"), decodeEntry(t, buf.Bytes())["user_id"])
+}
+
+func TestWithServiceObject(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("mycomponent", buf, 0, log.JSONEncoder{})
+	log.WithVersion("1.2.0", "abc123")(logger)
+	log.WithServiceObject(true)(logger)
+
+	logger.Info("hello, world", "city", "Athens", "service", "billing")
+
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, "billing", entry["service"])
+	require.Equal(t, map[string]interface{}{
+		log.ServiceNameKey: "mycomponent",
+		log.VersionKey:     "1.2.0",
+		log.CommitKey:      "abc123",
+	}, entry[log.ServiceKey])
+	require.NotContains(t, entry, log.ComponentKey)
+	require.NotContains(t, entry, log.VersionKey)
+	require.Equal(t, "Athens", entry["city"])
+
+	buf.Reset()
+	log.WithServiceObject(false)(logger)
+	logger.Info("hello, world")
+
+	entry = decodeEntry(t, buf.Bytes())
+	require.Equal(t, "mycomponent", entry[log.ComponentKey])
+	require.Equal(t, "1.2.0", entry[log.VersionKey])
+	require.NotContains(t, entry, log.ServiceKey)
+}
+
+func TestWithDropKeys(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{}, "internal_id", "abc")
+	log.WithDropKeys("internal_id", "trace_blob")(logger)
+
+	logger.WithValues("trace_blob", "xyz").Info("hello, world", "city", "Athens")
+
+	entry := decodeEntry(t, buf.Bytes())
+	require.NotContains(t, entry, "internal_id")
+	require.NotContains(t, entry, "trace_blob")
+	require.Equal(t, "Athens", entry["city"])
+}
+
+func TestWithAllowedKeys(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("test", buf, 0, log.JSONEncoder{}, "pod", "web-0")
+	log.WithAllowedKeys("city")(logger)
+	log.WithReplaceField(func(key string, value interface{}) (string, interface{}, bool) {
+		if key == "town" {
+			return "city", value, true
+		}
+		return key, value, true
+	})(logger)
+	log.WithAllowedKeys("request_id")(logger)
+
+	logger.Error(io.EOF, "hello, world", "town", "Athens", "request_id", "abc", "email", "alice@example.com")
+
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, "Athens", entry["city"], "expected the allowlist to apply after replacing fields")
+	require.Equal(t, "abc", entry["request_id"])
+	require.NotContains(t, entry, "email")
+	require.NotContains(t, entry, "pod")
+	require.Contains(t, entry, log.ErrorKey)
+	require.Equal(t, "test", entry[log.ComponentKey])
+	require.Equal(t, "hello, world", entry[log.MessageKey])
+}
+
+func TestWithFloatNotation(t *testing.T) {
+	tests := []struct {
+		notation    byte
+		small, huge string
+	}{
+		{notation: 'f', small: "0.000000123", huge: "123000000000000000000000"},
+		{notation: 'e', small: "1.23e-07", huge: "1.23e+23"},
+		{notation: 'g', small: "1.23e-07", huge: "1.23e+23"},
+	}
+	for _, test := range tests {
+		buf := bytes.NewBuffer(nil)
+		logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+		log.WithFloatNotation(test.notation)(logger)
+
+		logger.Info("hello, world", "small", 1.23e-7, "nested", map[string]interface{}{"huge": 1.23e23})
+
+		out := buf.String()
+		require.True(t, json.Valid(buf.Bytes()), out)
+		require.Contains(t, out, `"small":`+test.small+`}`, string(test.notation))
+		require.Contains(t, out, `"huge":`+test.huge+`}`, string(test.notation))
+
+		buf.Reset()
+		log.WithNumbersAsStrings(true)(logger)
+		logger.Info("hello, world", "small", 1.23e-7)
+		require.Equal(t, test.small, decodeEntry(t, buf.Bytes())["small"], string(test.notation))
+	}
+}
+
+func TestWithFloatNotation_SlicesAndArrays(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	log.WithFloatNotation('f')(logger)
+
+	logger.Info("hello, world",
+		"float32s", []float32{1e-7},
+		"array", [2]float64{1e-7, 2},
+		"matrix", [][]float64{{1e-7}},
+		"map", map[string]float64{"small": 1e-7},
+		"bytes", []byte("hi"),
+		"duration", []time.Duration{time.Second},
+	)
+
+	out := buf.String()
+	require.True(t, json.Valid(buf.Bytes()), out)
+	require.Contains(t, out, `"float32s":[0.0000001]`)
+	require.Contains(t, out, `"array":[0.0000001,2]`)
+	require.Contains(t, out, `"matrix":[[0.0000001]]`)
+	require.Contains(t, out, `"map":{"small":0.0000001}`)
+	require.Contains(t, out, `"bytes":"aGk="`)
+	require.Contains(t, out, `"duration":[1000000000]`)
+}
+
+func TestWithTimestampPrecision(t *testing.T) {
+	at := time.Date(2021, 6, 1, 12, 30, 45, 123456789, time.UTC)
+	for precision, want := range map[time.Duration]string{
+		0:                "2021-06-01T12:30:45.123456789Z",
+		time.Millisecond: "2021-06-01T12:30:45.123Z",
+		time.Second:      "2021-06-01T12:30:45Z",
+	} {
+		buf := bytes.NewBuffer(nil)
+		logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+		log.WithTimestampPrecision(precision)(logger)
+
+		logger.InfoAt(at, "hello, world")
+		require.Equal(t, want, decodeEntry(t, buf.Bytes())[log.TimeStampKey], precision.String())
+	}
+
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	log.WithTimestampPrecision(time.Second)(logger)
+	logger.Info("now")
+	ts, err := time.Parse(time.RFC3339Nano, decodeEntry(t, buf.Bytes())[log.TimeStampKey].(string))
+	require.NoError(t, err)
+	require.Zero(t, ts.Nanosecond())
+}
+
+func TestWithSchemaVersion(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	logger.Info("hello, world")
+	require.NotContains(t, decodeEntry(t, buf.Bytes()), log.SchemaVersionKey)
+
+	buf.Reset()
+	log.WithSchemaVersion("2")(logger)
+	logger.WithName("child").Info("hello, world")
+	require.Equal(t, "2", decodeEntry(t, buf.Bytes())[log.SchemaVersionKey])
+}
+
+func TestWithLocalTimestamp(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+3", 3*60*60)
+	defer func() { time.Local = local }()
+
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	log.WithLocalTimestamp("timestamp_local")(logger)
+
+	at := time.Date(2021, 6, 1, 12, 30, 45, 0, time.UTC)
+	logger.InfoAt(at, "hello, world")
+
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, "2021-06-01T12:30:45Z", entry[log.TimeStampKey])
+	require.Equal(t, "2021-06-01T15:30:45+03:00", entry["timestamp_local"])
+
+	utc, err := time.Parse(time.RFC3339Nano, entry[log.TimeStampKey].(string))
+	require.NoError(t, err)
+	localTS, err := time.Parse(time.RFC3339Nano, entry["timestamp_local"].(string))
+	require.NoError(t, err)
+	require.True(t, utc.Equal(localTS))
+}
+
+func TestWithLocalTimestamp_Info(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	log.WithLocalTimestamp("timestamp_local")(logger)
+
+	for i := 0; i < 100; i++ {
+		buf.Reset()
+		logger.Info("hello, world")
+
+		entry := decodeEntry(t, buf.Bytes())
+		utc, err := time.Parse(time.RFC3339Nano, entry[log.TimeStampKey].(string))
+		require.NoError(t, err)
+		localTS, err := time.Parse(time.RFC3339Nano, entry["timestamp_local"].(string))
+		require.NoError(t, err)
+		require.True(t, utc.Equal(localTS), "%s != %s", utc, localTS)
+	}
+
+	defer func(f func() string) { log.TimestampFunc = f }(log.TimestampFunc)
+	log.TimestampFunc = func() string { return "2021-06-01T12:30:45Z" }
+
+	buf.Reset()
+	logger.Info("hello, world")
+	localTS, err := time.Parse(time.RFC3339Nano, decodeEntry(t, buf.Bytes())["timestamp_local"].(string))
+	require.NoError(t, err)
+	require.True(t, localTS.Equal(time.Date(2021, 6, 1, 12, 30, 45, 0, time.UTC)))
+}
@@ -0,0 +1,74 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+// rateLimiter is a token bucket that caps the rate of entries. See
+// WithRateLimit
+type rateLimiter struct {
+	mtx sync.Mutex
+	// rate is the number of tokens added per second up to burst
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+	// dropped is the number of entries dropped since the last allowed one
+	dropped int
+}
+
+// newRateLimiter creates a full bucket of burst tokens refilled at perSecond
+func newRateLimiter(perSecond, burst int) *rateLimiter {
+	return &rateLimiter{
+		rate:   float64(perSecond),
+		burst:  float64(burst),
+		tokens: float64(burst),
+	}
+}
+
+// allow takes a token at now and reports whether there was one. If an entry
+// is allowed after entries were dropped, the number of dropped entries is
+// returned as well.
+func (r *rateLimiter) allow(now time.Time) (bool, int) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if !r.last.IsZero() {
+		r.tokens += now.Sub(r.last).Seconds() * r.rate
+		if r.tokens > r.burst {
+			r.tokens = r.burst
+		}
+	}
+	r.last = now
+
+	if r.tokens < 1 {
+		r.dropped++
+		return false, 0
+	}
+	r.tokens--
+	dropped := r.dropped
+	r.dropped = 0
+	return true, dropped
+}
+
+// limited reports whether the entry being logged by l is dropped by the rate
+// limit. Entries of unfiltered loggers, like the audit logger, are never
+// dropped.
+func (l *Logger) limited() bool {
+	r := l.opts.rateLimiter
+	if r == nil || l.opts.unfiltered {
+		return false
+	}
+	ok, dropped := r.allow(time.Now())
+	if !ok {
+		l.opts.stats.rateLimit()
+		return true
+	}
+	if dropped > 0 && l.opts.rateLimitSummary {
+		ll := l.clone()
+		ll.opts.rateLimiter = nil
+		ll.log(time.Time{}, "rate limit dropped entries", map[string]interface{}{RateLimitedKey: dropped}, nil)
+	}
+	return false
+}
@@ -0,0 +1,61 @@
+package log_test
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRateLimit_CapsEmittedEntries(t *testing.T) {
+	const (
+		perSecond = 10
+		burst     = 5
+		flood     = 1000
+	)
+
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("test", buf, 0, log.JSONEncoder{})
+	log.WithRateLimit(perSecond, burst)(logger)
+
+	start := time.Now()
+	for i := 0; i < flood; i++ {
+		logger.Info("flood", "i", i)
+	}
+	elapsed := time.Since(start)
+
+	emitted := strings.Count(buf.String(), "\n")
+	require.GreaterOrEqual(t, emitted, burst)
+	require.LessOrEqual(t, emitted, burst+int(elapsed.Seconds()*perSecond)+1)
+
+	stats := logger.Stats()
+	require.EqualValues(t, flood-emitted, stats.RateLimited)
+	require.EqualValues(t, emitted, stats.Entries)
+}
+
+func TestWithRateLimitSummary(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("test", buf, 0, log.JSONEncoder{})
+	log.WithRateLimit(20, 1)(logger)
+	log.WithRateLimitSummary(true)(logger)
+
+	logger.Info("first")
+	logger.Info("dropped")
+	logger.Info("dropped")
+	time.Sleep(100 * time.Millisecond)
+	logger.Info("second")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	require.Equal(t, "first", decodeEntry(t, []byte(lines[0]))[log.MessageKey])
+	require.EqualValues(t, 2, decodeEntry(t, []byte(lines[1]))[log.RateLimitedKey])
+	require.Equal(t, "second", decodeEntry(t, []byte(lines[2]))[log.MessageKey])
+}
+
+func TestWithRateLimit_NegativeIsInvalid(t *testing.T) {
+	err := log.InitE(t.Name(), []log.Option{log.WithRateLimit(-1, 1)})
+	require.Error(t, err)
+}
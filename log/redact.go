@@ -0,0 +1,173 @@
+package log
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"sync"
+)
+
+// Struct fields tagged with `log:"redact"` are logged as RedactedMask
+const (
+	RedactTagKey = "log"
+	RedactTag    = "redact"
+	RedactedMask = "***"
+)
+
+var (
+	// redactTypes caches whether a type has fields tagged with RedactTag
+	redactTypes sync.Map
+
+	marshalerType     = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// redactStructs replaces the values of context that are, point to or contain
+// structs with fields tagged with `log:"redact"` by maps in which those
+// fields are RedactedMask. The other fields are kept as encoding/json would
+// encode them. Values of all other types are left alone.
+func redactStructs(context map[string]interface{}) {
+	for k, v := range context {
+		if f, ok := v.(Field); ok {
+			v = f.Value()
+		}
+		t := reflect.TypeOf(v)
+		if t == nil || !hasRedactTags(t) {
+			continue
+		}
+		context[k] = redactValue(reflect.ValueOf(v))
+	}
+}
+
+// hasRedactTags reports whether t or the types it contains have fields tagged
+// with RedactTag
+func hasRedactTags(t reflect.Type) bool {
+	if has, ok := redactTypes.Load(t); ok {
+		return has.(bool)
+	}
+	has := findRedactTags(t, map[reflect.Type]bool{})
+	redactTypes.Store(t, has)
+	return has
+}
+
+// findRedactTags implements hasRedactTags. seen guards against recursive
+// types
+func findRedactTags(t reflect.Type, seen map[reflect.Type]bool) bool {
+	if seen[t] || t.Implements(marshalerType) || t.Implements(textMarshalerType) {
+		return false
+	}
+	seen[t] = true
+
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array:
+		return findRedactTags(t.Elem(), seen)
+	case reflect.Struct:
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if f.PkgPath != "" && !f.Anonymous {
+				continue
+			}
+			if isRedacted(f) || findRedactTags(f.Type, seen) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// isRedacted reports whether f is tagged with RedactTag
+func isRedacted(f reflect.StructField) bool {
+	for _, opt := range strings.Split(f.Tag.Get(RedactTagKey), ",") {
+		if opt == RedactTag {
+			return true
+		}
+	}
+	return false
+}
+
+// redactValue returns v with the structs it is, points to or contains
+// converted to maps with their redacted fields masked
+func redactValue(v reflect.Value) interface{} {
+	if !v.IsValid() {
+		return nil
+	}
+	if !hasRedactTags(v.Type()) {
+		return v.Interface()
+	}
+
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil
+		}
+		return redactValue(v.Elem())
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return nil
+		}
+		res := make([]interface{}, v.Len())
+		for i := range res {
+			res[i] = redactValue(v.Index(i))
+		}
+		return res
+	case reflect.Struct:
+		m := map[string]interface{}{}
+		redactStruct(m, v)
+		return m
+	default:
+		return v.Interface()
+	}
+}
+
+// redactStruct adds the fields of the struct v to m under their JSON names,
+// masking those tagged with RedactTag. The fields of embedded structs without
+// a JSON name are added as if they were fields of v.
+func redactStruct(m map[string]interface{}, v reflect.Value) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" && !f.Anonymous {
+			continue
+		}
+		name, opts := f.Name, ""
+		if tag, ok := f.Tag.Lookup("json"); ok {
+			if tag == "-" {
+				continue
+			}
+			parts := strings.SplitN(tag, ",", 2)
+			if parts[0] != "" {
+				name = parts[0]
+			}
+			if len(parts) > 1 {
+				opts = parts[1]
+			}
+		}
+
+		fv := v.Field(i)
+		if f.Anonymous && name == f.Name {
+			ev := fv
+			if ev.Kind() == reflect.Ptr {
+				if ev.IsNil() {
+					continue
+				}
+				ev = ev.Elem()
+			}
+			if ev.Kind() == reflect.Struct {
+				redactStruct(m, ev)
+				continue
+			}
+			if f.PkgPath != "" {
+				continue
+			}
+		}
+		if strings.Contains(","+opts+",", ",omitempty,") && fv.IsZero() {
+			continue
+		}
+		if isRedacted(f) {
+			m[name] = RedactedMask
+			continue
+		}
+		m[name] = redactValue(fv)
+	}
+}
@@ -0,0 +1,73 @@
+package log_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+type credentials struct {
+	User     string `json:"user"`
+	Password string `json:"password" log:"redact"`
+}
+
+type account struct {
+	credentials
+	ID      int            `json:"id"`
+	Token   string         `log:"redact"`
+	Backup  *credentials   `json:"backup,omitempty"`
+	Others  []credentials  `json:"others"`
+	Skipped string         `json:"-"`
+	Labels  map[string]int `json:"labels"`
+	secret  string
+}
+
+func TestRedactStructTags(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+
+	a := account{
+		credentials: credentials{User: "alice", Password: "hunter2"},
+		ID:          7,
+		Token:       "t0ken",
+		Others:      []credentials{{User: "bob", Password: "swordfish"}},
+		Skipped:     "skipped",
+		Labels:      map[string]int{"a": 1},
+		secret:      "unexported",
+	}
+	logger.Info("hello, world", "account", a, "ptr", &a.credentials)
+
+	require.NotContains(t, buf.String(), "hunter2")
+	require.NotContains(t, buf.String(), "swordfish")
+	require.NotContains(t, buf.String(), "t0ken")
+
+	entry := decodeEntry(t, buf.Bytes())
+	require.Equal(t, map[string]interface{}{
+		"user":     "alice",
+		"password": log.RedactedMask,
+		"id":       7.0,
+		"Token":    log.RedactedMask,
+		"others": []interface{}{
+			map[string]interface{}{"user": "bob", "password": log.RedactedMask},
+		},
+		"labels": map[string]interface{}{"a": 1.0},
+	}, entry["account"])
+	require.Equal(t, map[string]interface{}{
+		"user":     "alice",
+		"password": log.RedactedMask,
+	}, entry["ptr"])
+}
+
+func TestRedactStructTags_LeavesOtherStructsAlone(t *testing.T) {
+	type plain struct {
+		Name string `json:"name"`
+	}
+
+	buf := bytes.NewBuffer(nil)
+	logger := log.NewLogger("", buf, 0, log.JSONEncoder{})
+	logger.Info("hello, world", "plain", plain{Name: "alice"})
+
+	require.Equal(t, map[string]interface{}{"name": "alice"}, decodeEntry(t, buf.Bytes())["plain"])
+}
@@ -0,0 +1,77 @@
+package log
+
+import (
+	"net/http"
+	"sync"
+)
+
+// RingBufferSink keeps the most recent entries written to it in memory, e.g.
+// to show them on a debug endpoint, see ServeHTTP. Each Write is kept as one
+// entry, so it is usually added as a fanout target:
+//
+//	recent := log.NewRingBufferSink(100)
+//	log.InitWithOptions("app", []log.Option{
+//		log.WithFanout(log.Target{Encoder: log.JSONEncoder{}, Output: recent}),
+//	})
+//	http.Handle("/debug/logs", recent)
+//
+// It is safe for concurrent use.
+type RingBufferSink struct {
+	mtx     sync.Mutex
+	entries [][]byte
+	next    int
+	full    bool
+}
+
+// NewRingBufferSink creates a RingBufferSink that keeps the last n entries.
+// n is at least 1.
+func NewRingBufferSink(n int) *RingBufferSink {
+	if n < 1 {
+		n = 1
+	}
+	return &RingBufferSink{entries: make([][]byte, n)}
+}
+
+// Write keeps a copy of p as the most recent entry, replacing the oldest one
+// if the buffer is full
+func (r *RingBufferSink) Write(p []byte) (int, error) {
+	entry := append([]byte(nil), p...)
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+	r.entries[r.next] = entry
+	r.next = (r.next + 1) % len(r.entries)
+	if r.next == 0 {
+		r.full = true
+	}
+	return len(p), nil
+}
+
+// Recent returns copies of the kept entries from the oldest to the most
+// recent
+func (r *RingBufferSink) Recent() [][]byte {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	var entries [][]byte
+	if r.full {
+		entries = append(entries, r.entries[r.next:]...)
+	}
+	entries = append(entries, r.entries[:r.next]...)
+
+	res := make([][]byte, len(entries))
+	for i, e := range entries {
+		res[i] = append([]byte(nil), e...)
+	}
+	return res
+}
+
+// ServeHTTP writes the kept entries from the oldest to the most recent
+func (r *RingBufferSink) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	for _, e := range r.Recent() {
+		if _, err := w.Write(e); err != nil {
+			return
+		}
+	}
+}
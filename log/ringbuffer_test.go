@@ -0,0 +1,56 @@
+package log_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRingBufferSink_KeepsLastEntries(t *testing.T) {
+	recent := log.NewRingBufferSink(3)
+	logger := log.NewLogger("test", ioutil.Discard, 0, log.JSONEncoder{})
+	log.WithFanout(log.Target{Encoder: log.JSONEncoder{}, Output: recent})(logger)
+
+	require.Empty(t, recent.Recent())
+	for i := 0; i < 5; i++ {
+		logger.Info("hello, world", "i", i)
+	}
+
+	entries := recent.Recent()
+	require.Len(t, entries, 3)
+	for i, e := range entries {
+		require.EqualValues(t, i+2, decodeEntry(t, e)["i"])
+	}
+
+	rec := httptest.NewRecorder()
+	recent.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/logs", nil))
+	require.Equal(t, string(bytes.Join(entries, nil)), rec.Body.String())
+}
+
+func TestRingBufferSink_Concurrent(t *testing.T) {
+	recent := log.NewRingBufferSink(10)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				_, _ = recent.Write([]byte("entry\n"))
+				_ = recent.Recent()
+			}
+		}()
+	}
+	wg.Wait()
+
+	entries := recent.Recent()
+	require.Len(t, entries, 10)
+	require.Equal(t, strings.Repeat("entry\n", 10), string(bytes.Join(entries, nil)))
+}
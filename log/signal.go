@@ -0,0 +1,84 @@
+package log
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+)
+
+var (
+	signalMtx  sync.Mutex
+	signalStop func()
+
+	// notifyFunc and raiseFunc are replaced in tests
+	notifyFunc = signal.Notify
+	raiseFunc  = raise
+)
+
+// Drain flushes any buffered entries and closes the root logger. It is what
+// FlushOnSignal calls when a signal is received and can be called directly
+// from an application's own shutdown path.
+func Drain() error {
+	return Close()
+}
+
+// FlushOnSignal installs a handler that drains the root logger when one of
+// sig is received, so that no buffered entries are lost when the process is
+// terminated. After draining, the handler is removed and the signal is raised
+// again so that the process terminates as it would have without it.
+//
+// Handlers the application installed with signal.Notify keep receiving the
+// signal as well and run concurrently with the drain. Applications that
+// handle the signal themselves to shut down gracefully should call Drain at
+// the end of their shutdown instead of using FlushOnSignal, because the
+// signal is raised again once the logger is drained.
+//
+// Calling FlushOnSignal while a handler is installed returns the stop
+// function of that handler. stop removes the handler, restoring the prior
+// handling of sig, and is safe to call more than once.
+func FlushOnSignal(sig ...os.Signal) (stop func()) {
+	signalMtx.Lock()
+	defer signalMtx.Unlock()
+	if signalStop != nil {
+		return signalStop
+	}
+
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	notifyFunc(ch, sig...)
+
+	var once sync.Once
+	stop = func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+
+			signalMtx.Lock()
+			signalStop = nil
+			signalMtx.Unlock()
+		})
+	}
+	signalStop = stop
+
+	go func() {
+		select {
+		case s := <-ch:
+			_ = Drain()
+			stop()
+			raiseFunc(s)
+		case <-done:
+		}
+	}()
+	return stop
+}
+
+// raise sends s to the current process, exiting if that is not supported
+func raise(s os.Signal) {
+	p, err := os.FindProcess(os.Getpid())
+	if err == nil {
+		err = p.Signal(s)
+	}
+	if err != nil {
+		exitFunc(1)
+	}
+}
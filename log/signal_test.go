@@ -0,0 +1,82 @@
+package log_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDrain_FlushesBufferedEntries(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithFlushInterval(time.Hour),
+		log.WithFlushBytes(1 << 20),
+	})
+
+	log.Info("hello, world")
+	require.Zero(t, buf.Len(), "expected entry to be buffered")
+
+	require.NoError(t, log.Drain())
+	require.Contains(t, buf.String(), "hello, world")
+}
+
+func TestFlushOnSignal_IsIdempotent(t *testing.T) {
+	stop := log.FlushOnSignal(os.Interrupt)
+	again := log.FlushOnSignal(os.Interrupt)
+
+	stop()
+	again()
+	stop()
+
+	// a new handler can be installed after stop
+	log.FlushOnSignal(os.Interrupt)()
+}
+
+func TestFlushOnSignal_DrainsAndRaises(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		log.WithFlushInterval(time.Hour),
+		log.WithFlushBytes(1 << 20),
+	})
+	defer log.MustInit("")
+
+	registered := make(chan chan<- os.Signal, 1)
+	type raisedSignal struct {
+		sig     os.Signal
+		drained bool
+	}
+	raised := make(chan raisedSignal, 1)
+	restore := log.SetSignalFuncs(
+		func(ch chan<- os.Signal, sig ...os.Signal) {
+			require.Equal(t, []os.Signal{os.Interrupt}, sig)
+			registered <- ch
+		},
+		func(s os.Signal) {
+			// the entries must be drained before the signal is raised again
+			raised <- raisedSignal{sig: s, drained: strings.Contains(buf.String(), "hello, world")}
+		},
+	)
+	defer restore()
+
+	stop := log.FlushOnSignal(os.Interrupt)
+	defer stop()
+
+	log.Info("hello, world")
+	require.Zero(t, buf.Len(), "expected entry to be buffered")
+
+	(<-registered) <- os.Interrupt
+	select {
+	case r := <-raised:
+		require.Equal(t, os.Interrupt, r.sig)
+		require.True(t, r.drained, "expected the entries to be drained first")
+	case <-time.After(time.Second):
+		t.Fatal("expected the signal to be raised again")
+	}
+	require.Contains(t, buf.String(), "hello, world")
+}
@@ -0,0 +1,151 @@
+//go:build go1.21
+// +build go1.21
+
+package log
+
+import (
+	"context"
+	stdlog "log"
+	"log/slog"
+
+	"github.com/go-logr/logr"
+)
+
+// slogLeveler reports the log level as a slog.Level
+type slogLeveler struct{}
+
+// SlogLeveler returns a slog.Leveler that reports the current log level so
+// that slog handlers share the verbosity set with SetLogLevel. Verbosity v
+// maps to slog.LevelInfo-v, so verbosity 0 is slog.LevelInfo and verbosity 4
+// is slog.LevelDebug.
+func SlogLeveler() slog.Leveler {
+	return slogLeveler{}
+}
+
+// Level implements slog.Leveler
+func (slogLeveler) Level() slog.Level {
+	mtx.RLock()
+	defer mtx.RUnlock()
+	return slog.LevelInfo - slog.Level(logLevel)
+}
+
+// InstallAsSlogDefault sets slog.Default to a logger whose handler logs with
+// WithName(component), so that code using slog.Info and friends, as well as
+// the standard library's log package, logs through this package. The
+// returned function restores the previous default.
+func InstallAsSlogDefault(component string) (restore func()) {
+	prev := slog.Default()
+	writer, flags := stdlog.Writer(), stdlog.Flags()
+	slog.SetDefault(slog.New(SlogHandler(WithName(component))))
+	return func() {
+		slog.SetDefault(prev)
+		// slog.SetDefault doesn't reset the log package when restoring its
+		// builtin default
+		stdlog.SetOutput(writer)
+		stdlog.SetFlags(flags)
+	}
+}
+
+// slogHandler is a slog.Handler that logs with a logr.Logger
+type slogHandler struct {
+	logger logr.Logger
+}
+
+// SlogHandler returns a slog.Handler that logs records with l. Records at
+// slog.LevelError and above are logged with Error with a nil error, all
+// others with Info at the verbosity of their level as in SlogLeveler. Groups
+// are nested with Logger.WithGroup and dropped if l is not a *Logger.
+func SlogHandler(l logr.Logger) slog.Handler {
+	return slogHandler{logger: l}
+}
+
+// slogVerbosity maps level to a verbosity, see SlogLeveler
+func slogVerbosity(level slog.Level) int {
+	if level >= slog.LevelInfo {
+		return 0
+	}
+	return int(slog.LevelInfo - level)
+}
+
+// Enabled implements slog.Handler
+func (h slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	if level >= slog.LevelError {
+		return h.logger.Enabled()
+	}
+	return h.logger.V(slogVerbosity(level)).Enabled()
+}
+
+// Handle implements slog.Handler
+func (h slogHandler) Handle(_ context.Context, r slog.Record) error {
+	keysAndValues := make([]interface{}, 0, 2*r.NumAttrs())
+	r.Attrs(func(a slog.Attr) bool {
+		keysAndValues = appendSlogAttr(keysAndValues, a)
+		return true
+	})
+
+	if r.Level >= slog.LevelError {
+		h.logger.Error(nil, r.Message, keysAndValues...)
+		return nil
+	}
+	h.logger.V(slogVerbosity(r.Level)).Info(r.Message, keysAndValues...)
+	return nil
+}
+
+// WithAttrs implements slog.Handler
+func (h slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	keysAndValues := make([]interface{}, 0, 2*len(attrs))
+	for _, a := range attrs {
+		keysAndValues = appendSlogAttr(keysAndValues, a)
+	}
+	return slogHandler{logger: h.logger.WithValues(keysAndValues...)}
+}
+
+// WithGroup implements slog.Handler
+func (h slogHandler) WithGroup(name string) slog.Handler {
+	ll, ok := h.logger.(*Logger)
+	if !ok {
+		return h
+	}
+	return slogHandler{logger: ll.WithGroup(name)}
+}
+
+// appendSlogAttr appends the key and value of a to keysAndValues. Empty
+// attributes are ignored and the attributes of groups without a key are
+// inlined as slog.Handler requires.
+func appendSlogAttr(keysAndValues []interface{}, a slog.Attr) []interface{} {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return keysAndValues
+	}
+	if a.Value.Kind() == slog.KindGroup && a.Key == "" {
+		for _, ga := range a.Value.Group() {
+			keysAndValues = appendSlogAttr(keysAndValues, ga)
+		}
+		return keysAndValues
+	}
+	return append(keysAndValues, a.Key, slogValue(a.Value))
+}
+
+// slogValue converts v to a value to log. Groups become maps
+func slogValue(v slog.Value) interface{} {
+	if v.Kind() != slog.KindGroup {
+		return v.Any()
+	}
+	m := map[string]interface{}{}
+	for _, a := range v.Group() {
+		a.Value = a.Value.Resolve()
+		if a.Equal(slog.Attr{}) {
+			continue
+		}
+		if a.Value.Kind() == slog.KindGroup && a.Key == "" {
+			if nested, ok := slogValue(a.Value).(map[string]interface{}); ok {
+				for k, nv := range nested {
+					m[k] = nv
+				}
+			}
+			continue
+		}
+		m[a.Key] = slogValue(a.Value)
+	}
+	return m
+}
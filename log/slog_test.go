@@ -0,0 +1,51 @@
+//go:build go1.21
+// +build go1.21
+
+package log_test
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"testing"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSlogLeveler(t *testing.T) {
+	defer log.SetLogLevel(0)
+	leveler := log.SlogLeveler()
+	handler := slog.NewJSONHandler(io.Discard, &slog.HandlerOptions{Level: leveler})
+
+	log.SetLogLevel(0)
+	require.Equal(t, slog.LevelInfo, leveler.Level())
+	require.False(t, handler.Enabled(context.Background(), slog.LevelDebug))
+
+	log.SetLogLevel(4)
+	require.Equal(t, slog.LevelDebug, leveler.Level())
+	require.True(t, handler.Enabled(context.Background(), slog.LevelDebug))
+}
+
+func TestInstallAsSlogDefault(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions("app", []log.Option{log.WithOutput(buf)})
+	defer log.MustInit("")
+
+	prev := slog.Default()
+	restore := log.InstallAsSlogDefault("slog")
+
+	slog.Info("hello, world", "k", "v", slog.Group("req", "id", 7))
+	entry := decodeEntry(t, []byte(buf.String()))
+	require.Equal(t, "hello, world", entry[log.MessageKey])
+	require.Equal(t, "app_slog", entry[log.ComponentKey])
+	require.Equal(t, "v", entry["k"])
+	require.Equal(t, map[string]interface{}{"id": 7.0}, entry["req"])
+
+	restore()
+	require.Same(t, prev, slog.Default())
+
+	n := buf.Len()
+	slog.Info("not captured")
+	require.Equal(t, n, buf.Len())
+}
@@ -0,0 +1,74 @@
+package log
+
+import (
+	"sync/atomic"
+)
+
+// Stats are the counters of a logger and the loggers derived from it
+type Stats struct {
+	// Entries is the number of entries written
+	Entries uint64
+	// Suppressed is the number of entries suppressed by WithCollapseRepeats
+	Suppressed uint64
+	// RateLimited is the number of entries dropped by WithRateLimit
+	RateLimited uint64
+	// EncodeFailures is the number of entries the encoder failed to encode
+	// or panicked on. Fanout targets are counted separately
+	EncodeFailures uint64
+	// Sequence is the next sequence number if WithSequenceNumbers is
+	// enabled
+	Sequence uint64
+}
+
+// counters are updated with atomic operations only, so that counting doesn't
+// serialize loggers that are used concurrently
+type counters struct {
+	entries        uint64
+	suppressed     uint64
+	rateLimited    uint64
+	encodeFailures uint64
+}
+
+// entry counts a written entry
+func (c *counters) entry() {
+	if c != nil {
+		atomic.AddUint64(&c.entries, 1)
+	}
+}
+
+// suppress counts a suppressed entry
+func (c *counters) suppress() {
+	if c != nil {
+		atomic.AddUint64(&c.suppressed, 1)
+	}
+}
+
+// rateLimit counts an entry dropped by the rate limit
+func (c *counters) rateLimit() {
+	if c != nil {
+		atomic.AddUint64(&c.rateLimited, 1)
+	}
+}
+
+// encodeFailed counts an entry that failed to encode
+func (c *counters) encodeFailed() {
+	if c != nil {
+		atomic.AddUint64(&c.encodeFailures, 1)
+	}
+}
+
+// Stats returns the current counters of l. The counters are shared by all
+// loggers derived from l and are read atomically without blocking logging
+func (l *Logger) Stats() Stats {
+	var s Stats
+	if c := l.opts.stats; c != nil {
+		s.Entries = atomic.LoadUint64(&c.entries)
+		s.Suppressed = atomic.LoadUint64(&c.suppressed)
+		s.RateLimited = atomic.LoadUint64(&c.rateLimited)
+		s.EncodeFailures = atomic.LoadUint64(&c.encodeFailures)
+	}
+	if l.opts.seq != nil {
+		s.Sequence = atomic.LoadUint64(l.opts.seq)
+	}
+	return s
+}
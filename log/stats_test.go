@@ -0,0 +1,61 @@
+package log_test
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLogger_Stats_Concurrent(t *testing.T) {
+	const (
+		goroutines = 8
+		entries    = 100
+	)
+
+	logger := log.NewLogger("test", ioutil.Discard, 0, log.JSONEncoder{})
+	log.WithSequenceNumbers(true)(logger)
+
+	var wg sync.WaitGroup
+	for i := 0; i < goroutines; i++ {
+		wg.Add(1)
+		go func(l *log.Logger) {
+			defer wg.Done()
+			for j := 0; j < entries; j++ {
+				l.Info("hello, world")
+				_ = l.Stats()
+			}
+		}(logger.WithValues("goroutine", i).(*log.Logger))
+	}
+	wg.Wait()
+
+	stats := logger.Stats()
+	require.EqualValues(t, goroutines*entries, stats.Entries)
+	require.EqualValues(t, goroutines*entries, stats.Sequence)
+	require.Zero(t, stats.EncodeFailures)
+}
+
+func TestLogger_Stats_EncodeFailuresAndSuppressed(t *testing.T) {
+	enc := fakeEncoder{
+		EncodeFunc: func(io.Writer, interface{}) error {
+			panic("boom")
+		},
+	}
+	logger := log.NewLogger("test", &bytes.Buffer{}, 0, enc)
+	log.WithCollapseRepeats(time.Hour)(logger)
+
+	logger.Info("hello, world")
+	logger.Info("hello, world")
+	logger.Info("hello, world")
+
+	stats := logger.Stats()
+	require.EqualValues(t, 1, stats.Entries)
+	require.EqualValues(t, 2, stats.Suppressed)
+	require.EqualValues(t, 1, stats.EncodeFailures)
+	require.NoError(t, logger.Close())
+}
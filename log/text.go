@@ -0,0 +1,193 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ViaQ/logerr/kverrors"
+)
+
+// LogfmtEncoder encodes entries as logfmt key=value pairs using the same keys
+// and order as the JSONEncoder. Like the JSONEncoder it leaves out empty
+// messages. Labels are written before the fields with their keys prefixed by
+// LabelsKey, e.g. _labels.app=web
+type LogfmtEncoder struct {
+	// ComponentKey is the key of the component instead of ComponentKey
+	ComponentKey string
+}
+
+// Encode encodes the entry as logfmt to w
+func (e LogfmtEncoder) Encode(w io.Writer, entry interface{}) error {
+	m, ok := entry.(Entry)
+	if !ok {
+		return unsupportedEntry(entry)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	write := func(key string, value interface{}) {
+		if buf.Len() > 0 {
+			buf.WriteByte(' ')
+		}
+		buf.WriteString(logfmtKey(key))
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(value))
+	}
+
+	write(TimeStampKey, m.Timestamp)
+	if m.Verbosity > 1 {
+		write(FileLineKey, m.Caller)
+	}
+	write(LevelKey, m.Level)
+	write(or(e.ComponentKey, ComponentKey), m.Component)
+	if m.Message != "" {
+		write(MessageKey, m.Message)
+	}
+	for _, k := range sortedLabelKeys(m.Labels) {
+		write(labelKey(k), m.Labels[k])
+	}
+	for _, k := range sortedKeys(m.Fields) {
+		write(k, m.Fields[k])
+	}
+
+	buf.WriteByte('\n')
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// ConsoleEncoder encodes entries for humans reading them in a terminal: the
+// timestamp, level, component and message separated by tabs followed by the
+// labels and fields as logfmt key=value pairs like the LogfmtEncoder writes
+// them
+type ConsoleEncoder struct{}
+
+// Encode encodes the entry for a console to w
+func (ConsoleEncoder) Encode(w io.Writer, entry interface{}) error {
+	m, ok := entry.(Entry)
+	if !ok {
+		return unsupportedEntry(entry)
+	}
+
+	buf := bytes.NewBuffer(nil)
+	columns := []string{m.Timestamp, m.Level, m.Component}
+	if m.Verbosity > 1 {
+		columns = append(columns, m.Caller)
+	}
+	columns = append(columns, consoleEscaper.Replace(m.Message))
+	buf.WriteString(strings.Join(columns, "\t"))
+
+	pairs := 0
+	write := func(key string, value interface{}) {
+		if pairs == 0 {
+			buf.WriteByte('\t')
+		} else {
+			buf.WriteByte(' ')
+		}
+		pairs++
+		buf.WriteString(logfmtKey(key))
+		buf.WriteByte('=')
+		buf.WriteString(logfmtValue(value))
+	}
+	for _, k := range sortedLabelKeys(m.Labels) {
+		write(labelKey(k), m.Labels[k])
+	}
+	for _, k := range sortedKeys(m.Fields) {
+		write(k, m.Fields[k])
+	}
+
+	buf.WriteByte('\n')
+	_, err := w.Write(buf.Bytes())
+	return err
+}
+
+// consoleEscaper keeps messages on a single line
+var consoleEscaper = strings.NewReplacer("\n", `\n`, "\r", `\r`)
+
+// unsupportedEntry returns the error for entries that are not an Entry
+func unsupportedEntry(entry interface{}) error {
+	return kverrors.New("unsupported entry type",
+		"entry_type", fmt.Sprintf("%T", entry),
+		"expected_type", fmt.Sprintf("%T", Entry{}),
+	)
+}
+
+// sortedKeys returns the keys of m in sorted order
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// sortedLabelKeys returns the keys of labels in sorted order
+func sortedLabelKeys(labels map[string]string) []string {
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// labelKey returns the key a label is written under by the text encoders
+func labelKey(k string) string {
+	return LabelsKey + "." + k
+}
+
+// logfmtValue formats v as a logfmt value. Strings and plain errors are
+// written as they are, everything else as JSON. Values are quoted if they
+// contain spaces, quotes, '=' or control characters.
+func logfmtValue(v interface{}) string {
+	if f, ok := v.(Field); ok {
+		v = f.Value()
+	}
+
+	var s string
+	switch x := v.(type) {
+	case string:
+		s = x
+	case json.Marshaler:
+		s = jsonValue(x)
+	case error:
+		s = x.Error()
+	default:
+		s = jsonValue(x)
+	}
+
+	return logfmtQuote(s)
+}
+
+// logfmtKey formats k as a logfmt key. Keys are quoted like values so a key
+// with spaces, quotes or '=' cannot break up the pair
+func logfmtKey(k string) string {
+	return logfmtQuote(k)
+}
+
+// logfmtQuote quotes s if it is empty or contains spaces, quotes, '=' or
+// control characters
+func logfmtQuote(s string) string {
+	if s == "" {
+		return `""`
+	}
+	for i := 0; i < len(s); i++ {
+		if c := s[i]; c <= ' ' || c == '=' || c == '"' || c == 0x7f {
+			return strconv.Quote(s)
+		}
+	}
+	return s
+}
+
+// jsonValue returns v encoded as JSON or, if that fails, formatted by fmt
+func jsonValue(v interface{}) string {
+	b, err := marshalJSON(v, false)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
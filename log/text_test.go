@@ -0,0 +1,118 @@
+package log_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/ViaQ/logerr/kverrors"
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func textEntry() log.Entry {
+	return log.Entry{
+		Timestamp: "2021-01-01T00:00:00Z",
+		Level:     "0",
+		Component: "text",
+		Message:   "hello, world",
+		Fields: map[string]interface{}{
+			"city":    "Athens",
+			"attempt": 1,
+			"empty":   "",
+			"quote":   `say "hi"`,
+			"err":     errors.New("fail boat"),
+			"kverr":   kverrors.New("sunk", "ship", "titanic"),
+			"tags":    []string{"a", "b"},
+		},
+	}
+}
+
+func TestLogfmtEncoder(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, log.LogfmtEncoder{}.Encode(buf, textEntry()))
+
+	expected := `_ts=2021-01-01T00:00:00Z _level=0 _component=text _message="hello, world" ` +
+		`attempt=1 city=Athens empty="" err="fail boat" kverr="{\"msg\":\"sunk\",\"ship\":\"titanic\"}" ` +
+		`quote="say \"hi\"" tags="[\"a\",\"b\"]"` + "\n"
+	require.Equal(t, expected, buf.String())
+}
+
+func TestLogfmtEncoder_ComponentKey(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, log.LogfmtEncoder{ComponentKey: "logger"}.Encode(buf, log.Entry{Component: "text", Message: "hello"}))
+	require.Contains(t, buf.String(), " logger=text ")
+}
+
+func TestConsoleEncoder(t *testing.T) {
+	entry := textEntry()
+	entry.Message = "hello,\nworld"
+	entry.Fields = map[string]interface{}{"city": "Athens", "attempt": 1}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, log.ConsoleEncoder{}.Encode(buf, entry))
+	require.Equal(t, "2021-01-01T00:00:00Z\t0\ttext\thello,\\nworld\tattempt=1 city=Athens\n", buf.String())
+}
+
+func TestTextEncoders_UnsupportedEntry(t *testing.T) {
+	require.Error(t, log.LogfmtEncoder{}.Encode(&bytes.Buffer{}, "hello"))
+	require.Error(t, log.ConsoleEncoder{}.Encode(&bytes.Buffer{}, "hello"))
+}
+
+func TestLogfmtEncoder_OmitsEmptyMessage(t *testing.T) {
+	buf := &bytes.Buffer{}
+	require.NoError(t, log.LogfmtEncoder{}.Encode(buf, log.Entry{Component: "text"}))
+	require.NotContains(t, buf.String(), log.MessageKey)
+
+	buf.Reset()
+	require.NoError(t, log.LogfmtEncoder{}.Encode(buf, log.Entry{Message: " "}))
+	require.Contains(t, buf.String(), log.MessageKey+`=" "`)
+}
+
+func TestLogfmtEncoder_Labels(t *testing.T) {
+	entry := log.Entry{
+		Component: "text",
+		Message:   "hello",
+		Labels:    map[string]string{"env": "prod", "app": "web server"},
+		Fields:    map[string]interface{}{"city": "Athens"},
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, log.LogfmtEncoder{}.Encode(buf, entry))
+	require.Contains(t, buf.String(), ` _message=hello _labels.app="web server" _labels.env=prod city=Athens`+"\n")
+}
+
+func TestConsoleEncoder_Labels(t *testing.T) {
+	entry := textEntry()
+	entry.Labels = map[string]string{"env": "prod"}
+	entry.Fields = map[string]interface{}{"city": "Athens"}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, log.ConsoleEncoder{}.Encode(buf, entry))
+	require.Equal(t, "2021-01-01T00:00:00Z\t0\ttext\thello, world\t_labels.env=prod city=Athens\n", buf.String())
+
+	buf.Reset()
+	entry.Fields = nil
+	require.NoError(t, log.ConsoleEncoder{}.Encode(buf, entry))
+	require.Equal(t, "2021-01-01T00:00:00Z\t0\ttext\thello, world\t_labels.env=prod\n", buf.String())
+}
+
+func TestLogfmtEncoder_QuotesKeys(t *testing.T) {
+	entry := log.Entry{
+		Component: "text",
+		Fields: map[string]interface{}{
+			"with space": 1,
+			"a=b":        2,
+			`say"hi"`:    3,
+			"":           4,
+		},
+	}
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, log.LogfmtEncoder{}.Encode(buf, entry))
+	require.Contains(t, buf.String(), `_component=text ""=4 "a=b"=2 "say\"hi\""=3 "with space"=1`+"\n")
+
+	buf.Reset()
+	require.NoError(t, log.ConsoleEncoder{}.Encode(buf, entry))
+	require.Contains(t, buf.String(), `""=4 "a=b"=2 "say\"hi\""=3 "with space"=1`+"\n")
+}
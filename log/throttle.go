@@ -0,0 +1,59 @@
+package log
+
+import (
+	"sync"
+	"time"
+)
+
+var (
+	throttleMtx sync.Mutex
+	// throttles holds the state of the keys of InfoThrottled
+	throttles = map[string]*throttle{}
+)
+
+// throttle is the state of a key of InfoThrottled
+type throttle struct {
+	last       time.Time
+	suppressed int
+}
+
+// InfoThrottled logs a message like Info at most once every minInterval for
+// the same key, e.g. for a noisy message in a loop, and drops it otherwise.
+// The number of dropped calls since the last entry for key is added to the
+// next entry under ThrottledKey. Unlike WithRateLimit it only affects the
+// calls sharing key. The state of every key is kept for the lifetime of the
+// process, so keys must be chosen from a bounded set, such as constants, and
+// never from unbounded values like request ids.
+func InfoThrottled(key string, minInterval time.Duration, msg string, keysAndValues ...interface{}) {
+	suppressed, ok := throttled(key, minInterval, time.Now())
+	if !ok {
+		return
+	}
+	if suppressed > 0 {
+		keysAndValues = append(append([]interface{}{}, keysAndValues...), ThrottledKey, suppressed)
+	}
+
+	mtx.RLock()
+	defer mtx.RUnlock()
+	root().Info(msg, keysAndValues...)
+}
+
+// throttled reports whether a call for key at now may be logged and, if so,
+// how many calls were suppressed since the last one that was
+func throttled(key string, minInterval time.Duration, now time.Time) (int, bool) {
+	throttleMtx.Lock()
+	defer throttleMtx.Unlock()
+
+	t, ok := throttles[key]
+	if !ok {
+		throttles[key] = &throttle{last: now}
+		return 0, true
+	}
+	if now.Sub(t.last) < minInterval {
+		t.suppressed++
+		return 0, false
+	}
+	suppressed := t.suppressed
+	t.last, t.suppressed = now, 0
+	return suppressed, true
+}
@@ -0,0 +1,39 @@
+package log_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInfoThrottled(t *testing.T) {
+	buf := &syncBuffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf)})
+	defer log.MustInit("")
+
+	key := t.Name()
+	interval := 50 * time.Millisecond
+	for i := 0; i < 5; i++ {
+		log.InfoThrottled(key, interval, "noisy", "i", i)
+	}
+	log.InfoThrottled(key+"-other", interval, "other")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 2)
+	first := decodeEntry(t, []byte(lines[0]))
+	require.EqualValues(t, 0, first["i"])
+	require.NotContains(t, first, log.ThrottledKey)
+	require.Equal(t, "other", decodeEntry(t, []byte(lines[1]))[log.MessageKey])
+
+	time.Sleep(interval)
+	log.InfoThrottled(key, interval, "noisy", "i", 5)
+
+	lines = strings.Split(strings.TrimSpace(buf.String()), "\n")
+	require.Len(t, lines, 3)
+	next := decodeEntry(t, []byte(lines[2]))
+	require.EqualValues(t, 5, next["i"])
+	require.EqualValues(t, 4, next[log.ThrottledKey])
+}
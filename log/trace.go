@@ -0,0 +1,25 @@
+package log
+
+import (
+	"context"
+	"runtime/trace"
+)
+
+// traceCategory is the category of entries of loggers without a component
+const traceCategory = "logerr"
+
+// traceLog logs msg to the execution tracer if it is running
+func (l *Logger) traceLog(msg string) {
+	if !trace.IsEnabled() {
+		return
+	}
+	ctx := l.opts.traceCtx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	category := l.name
+	if category == "" {
+		category = traceCategory
+	}
+	trace.Log(ctx, category, msg)
+}
@@ -0,0 +1,33 @@
+package log_test
+
+import (
+	"bytes"
+	"context"
+	"runtime/trace"
+	"testing"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWithRuntimeTrace(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{log.WithOutput(buf), log.WithRuntimeTrace(true)})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	// without the tracer running entries are only written to the output
+	log.Info("untraced")
+	require.Contains(t, buf.String(), "untraced")
+
+	events := &bytes.Buffer{}
+	if err := trace.Start(events); err != nil {
+		t.Skipf("execution tracer is not available: %s", err)
+	}
+	ctx, task := trace.NewTask(context.Background(), "request")
+	log.FromContext(ctx).Info("traced")
+	task.End()
+	trace.Stop()
+
+	require.Contains(t, buf.String(), "traced")
+	require.Contains(t, events.String(), "traced")
+}
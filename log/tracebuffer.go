@@ -0,0 +1,81 @@
+package log
+
+import (
+	"context"
+	"sync"
+)
+
+// TraceBufferSize is the number of entries TraceBuffer holds at most. Once
+// it is reached the oldest entries are dropped so a long running request
+// cannot hold an unbounded number of entries.
+const TraceBufferSize = 1000
+
+// TraceBuffer returns a copy of ctx whose logger, see FromContext, holds its
+// entries in memory instead of writing them. Calling the returned function
+// with flush set writes the held entries in the order they were logged,
+// otherwise they are discarded. Either way entries logged afterwards are
+// written immediately and further calls have no effect. This keeps the full
+// trace of requests that fail without the noise of those that succeed:
+//
+//	ctx, done := log.TraceBuffer(r.Context())
+//	err := handle(ctx, r)
+//	done(err != nil)
+//
+// Entries are only held if the logger of ctx is *log.Logger. Only the last
+// TraceBufferSize entries are held.
+func TraceBuffer(ctx context.Context) (context.Context, func(flush bool)) {
+	b := &traceBuffer{}
+	cl, _ := ctx.Value(contextKey{}).(contextLogger)
+	cl.buffer = b
+	return context.WithValue(ctx, contextKey{}, cl), b.end
+}
+
+// traceBuffer holds the last TraceBufferSize entries and the loggers that
+// logged them until end is called. Like RingBufferSink it replaces the
+// oldest entry once it is full.
+type traceBuffer struct {
+	mtx     sync.Mutex
+	done    bool
+	entries []bufferedEntry
+	next    int
+}
+
+// bufferedEntry is an entry held by a traceBuffer
+type bufferedEntry struct {
+	logger *Logger
+	entry  Entry
+}
+
+// add holds m, logged by l, and reports whether it was held. Entries are not
+// held after end was called.
+func (b *traceBuffer) add(l *Logger, m Entry) bool {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	if b.done {
+		return false
+	}
+	e := bufferedEntry{logger: l, entry: m}
+	if len(b.entries) < TraceBufferSize {
+		b.entries = append(b.entries, e)
+		return true
+	}
+	b.entries[b.next] = e
+	b.next = (b.next + 1) % len(b.entries)
+	return true
+}
+
+// end writes the held entries if flush is set and discards them otherwise
+func (b *traceBuffer) end(flush bool) {
+	b.mtx.Lock()
+	// oldest first, the oldest entry is at next once the buffer is full
+	entries := append(b.entries[b.next:len(b.entries):len(b.entries)], b.entries[:b.next]...)
+	b.entries, b.next, b.done = nil, 0, true
+	b.mtx.Unlock()
+
+	if !flush {
+		return
+	}
+	for _, e := range entries {
+		e.logger.emit(e.entry)
+	}
+}
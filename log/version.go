@@ -0,0 +1,31 @@
+//go:build go1.18
+// +build go1.18
+
+package log
+
+import (
+	"runtime/debug"
+)
+
+// buildVersion returns the module version and VCS revision from the build
+// info of the binary
+func buildVersion() (version, commit string) {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return "", ""
+	}
+	for _, s := range bi.Settings {
+		if s.Key == "vcs.revision" {
+			commit = s.Value
+		}
+	}
+	return moduleVersion(bi), commit
+}
+
+// moduleVersion returns the version of the main module unless it is unknown
+func moduleVersion(bi *debug.BuildInfo) string {
+	if bi.Main.Version == "(devel)" {
+		return ""
+	}
+	return bi.Main.Version
+}
@@ -0,0 +1,18 @@
+//go:build !go1.18
+// +build !go1.18
+
+package log
+
+import (
+	"runtime/debug"
+)
+
+// buildVersion returns the module version from the build info of the
+// binary. The VCS revision requires Go 1.18
+func buildVersion() (version, commit string) {
+	bi, ok := debug.ReadBuildInfo()
+	if !ok || bi.Main.Version == "(devel)" {
+		return "", ""
+	}
+	return bi.Main.Version, ""
+}
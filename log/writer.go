@@ -0,0 +1,98 @@
+package log
+
+import (
+	"bytes"
+	"sync"
+	"unicode/utf8"
+)
+
+// LineWriter is an io.Writer that logs every line written to it as the
+// message of an entry. See Writer
+type LineWriter struct {
+	mtx     sync.Mutex
+	level   int
+	partial bytes.Buffer
+}
+
+// Writer returns a writer that logs each line written to it with the root
+// logger at verbosity level. This allows capturing the output of libraries
+// that only accept an io.Writer. Partial lines are buffered until they are
+// completed or Flush is called.
+func Writer(level int) *LineWriter {
+	return &LineWriter{level: level}
+}
+
+// Write logs every complete line of p as a separate entry and buffers the
+// remainder
+func (w *LineWriter) Write(p []byte) (int, error) {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+
+	n := len(p)
+	for len(p) > 0 {
+		i := bytes.IndexByte(p, '\n')
+		if i < 0 {
+			w.partial.Write(p)
+			break
+		}
+		w.partial.Write(p[:i])
+		w.emit()
+		p = p[i+1:]
+	}
+	return n, nil
+}
+
+// Flush logs the buffered partial line, if any
+func (w *LineWriter) Flush() error {
+	w.mtx.Lock()
+	defer w.mtx.Unlock()
+	if w.partial.Len() > 0 {
+		w.emit()
+	}
+	return nil
+}
+
+// emit logs the buffered line and resets the buffer
+func (w *LineWriter) emit() {
+	msg := string(bytes.TrimSuffix(w.partial.Bytes(), []byte{'\r'}))
+	w.partial.Reset()
+	V(w.level).Info(msg)
+}
+
+// lockedBuffer is a bytes.Buffer that is safe for concurrent use
+type lockedBuffer struct {
+	mtx sync.Mutex
+	buf bytes.Buffer
+}
+
+// Write appends p to the buffer
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return b.buf.Write(p)
+}
+
+// Bytes returns a copy of the contents of the buffer
+func (b *lockedBuffer) Bytes() []byte {
+	b.mtx.Lock()
+	defer b.mtx.Unlock()
+	return append([]byte(nil), b.buf.Bytes()...)
+}
+
+// terminate replaces the trailing newline of the entry b, if any, with
+// terminator. See WithLineTerminator
+func terminate(b []byte, terminator string) []byte {
+	if len(b) == 0 || b[len(b)-1] != '\n' {
+		return b
+	}
+	return append(b[:len(b)-1], terminator...)
+}
+
+// sanitizeUTF8 replaces invalid UTF-8 in the entry b with U+FFFD. See
+// WithSanitizeUTF8
+func sanitizeUTF8(b []byte) []byte {
+	if utf8.Valid(b) {
+		return b
+	}
+	return bytes.ToValidUTF8(b, []byte(string(utf8.RuneError)))
+}
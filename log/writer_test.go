@@ -0,0 +1,47 @@
+package log_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriter_SplitsLines(t *testing.T) {
+	obs, logger := NewObservedLogger()
+	log.UseLogger(logger)
+
+	w := log.Writer(0)
+	_, err := fmt.Fprint(w, "first\nsecond\r\nthi")
+	require.NoError(t, err)
+
+	logs := obs.TakeAll()
+	require.Len(t, logs, 2)
+	require.Equal(t, "first", logs[0].Message)
+	require.Equal(t, "second", logs[1].Message)
+
+	_, err = fmt.Fprint(w, "rd\nfourth")
+	require.NoError(t, err)
+	logs = obs.TakeAll()
+	require.Len(t, logs, 1)
+	require.Equal(t, "third", logs[0].Message)
+
+	require.NoError(t, w.Flush())
+	logs = obs.TakeAll()
+	require.Len(t, logs, 1)
+	require.Equal(t, "fourth", logs[0].Message)
+
+	require.NoError(t, w.Flush())
+	require.Empty(t, obs.TakeAll())
+}
+
+func TestWriter_HonorsVerbosity(t *testing.T) {
+	obs, logger := NewObservedLogger()
+	log.UseLogger(logger)
+	log.SetLogLevel(0)
+
+	_, err := fmt.Fprintln(log.Writer(1), "hidden")
+	require.NoError(t, err)
+	require.Empty(t, obs.TakeAll())
+}
@@ -0,0 +1,18 @@
+module github.com/ViaQ/logerr/loggrpc
+
+go 1.17
+
+require (
+	github.com/ViaQ/logerr v1.0.10
+	github.com/stretchr/testify v1.7.0
+	google.golang.org/grpc v1.45.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/go-logr/logr v0.4.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v3 v3.0.0-20200313102051-9f266ea9e77c // indirect
+)
+
+replace github.com/ViaQ/logerr => ../
@@ -0,0 +1,28 @@
+// Package loggrpc finds correlation ids in gRPC metadata
+//
+// loggrpc is a separate module so that the gRPC dependency is only required
+// by applications that use it
+package loggrpc
+
+import (
+	"context"
+
+	"github.com/ViaQ/logerr/log"
+	"google.golang.org/grpc/metadata"
+)
+
+// Correlation returns an extractor for the correlation id in the incoming
+// metadata key of a gRPC server context. See log.WithCorrelation
+func Correlation(key string) log.CorrelationExtractor {
+	return log.CorrelationExtractorFunc(func(ctx context.Context) (string, bool) {
+		md, ok := metadata.FromIncomingContext(ctx)
+		if !ok {
+			return "", false
+		}
+		values := md.Get(key)
+		if len(values) == 0 || values[0] == "" {
+			return "", false
+		}
+		return values[0], true
+	})
+}
@@ -0,0 +1,22 @@
+package loggrpc_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/ViaQ/logerr/loggrpc"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestCorrelation(t *testing.T) {
+	extractor := loggrpc.Correlation("x-request-id")
+
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-request-id", "abc"))
+	id, ok := extractor.CorrelationID(ctx)
+	require.True(t, ok)
+	require.Equal(t, "abc", id)
+
+	_, ok = extractor.CorrelationID(context.Background())
+	require.False(t, ok)
+}
@@ -0,0 +1,18 @@
+module github.com/ViaQ/logerr/logproto
+
+go 1.17
+
+require (
+	github.com/ViaQ/logerr v1.0.10
+	github.com/stretchr/testify v1.4.0
+	google.golang.org/protobuf v1.27.1
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.0 // indirect
+	github.com/go-logr/logr v0.4.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/yaml.v2 v2.2.2 // indirect
+)
+
+replace github.com/ViaQ/logerr => ../
@@ -0,0 +1,35 @@
+// Package logproto logs protobuf messages as their protojson encoding
+// instead of encoding their Go structs with reflection
+//
+// logproto is a separate module so that the protobuf dependency is only
+// required by applications that use it
+package logproto
+
+import (
+	"encoding/json"
+
+	"github.com/ViaQ/logerr/log"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// WithProtoJSON returns an option that logs values implementing proto.Message
+// as nested JSON using protojson
+func WithProtoJSON() log.Option {
+	return log.WithValueFormatter(Format)
+}
+
+// Format is a log.ValueFormatter that formats proto.Message values as
+// protojson. Messages that cannot be encoded are logged as the error
+// describing why.
+func Format(v interface{}) (interface{}, bool) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, false
+	}
+	b, err := protojson.Marshal(m)
+	if err != nil {
+		return err.Error(), true
+	}
+	return json.RawMessage(b), true
+}
@@ -0,0 +1,42 @@
+package logproto_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/ViaQ/logerr/logproto"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/structpb"
+)
+
+func TestWithProtoJSON(t *testing.T) {
+	msg, err := structpb.NewStruct(map[string]interface{}{
+		"name":  "checkout",
+		"items": []interface{}{"book", "pen"},
+	})
+	require.NoError(t, err)
+
+	buf := &bytes.Buffer{}
+	log.MustInitWithOptions(t.Name(), []log.Option{
+		log.WithOutput(buf),
+		logproto.WithProtoJSON(),
+	})
+	defer func() { require.NoError(t, log.Close()) }()
+
+	log.Info("event", "payload", msg, "user", "alice")
+
+	entry := map[string]interface{}{}
+	require.NoError(t, json.Unmarshal(buf.Bytes(), &entry))
+	require.Equal(t, map[string]interface{}{
+		"name":  "checkout",
+		"items": []interface{}{"book", "pen"},
+	}, entry["payload"])
+	require.Equal(t, "alice", entry["user"])
+}
+
+func TestFormat_IgnoresOtherValues(t *testing.T) {
+	_, ok := logproto.Format("hello")
+	require.False(t, ok)
+}
@@ -0,0 +1,5 @@
+// Package logtest provides helpers for asserting on log output in tests
+//
+// logtest is kept separate from pkg/log so that it is not compiled into
+// production binaries
+package logtest
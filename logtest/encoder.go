@@ -0,0 +1,97 @@
+package logtest
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ViaQ/logerr/log"
+)
+
+// EncoderConformance runs tests verifying that enc meets the requirements of
+// log.Encoder. Use it from the tests of a custom encoder:
+//
+//	func TestMyEncoder(t *testing.T) {
+//	    logtest.EncoderConformance(t, MyEncoder{})
+//	}
+func EncoderConformance(t *testing.T, enc log.Encoder) {
+	t.Helper()
+
+	t.Run("EncodesEmptyEntry", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		if err := enc.Encode(buf, log.Entry{}); err != nil {
+			t.Fatalf("failed to encode empty entry: %s", err)
+		}
+	})
+
+	t.Run("EncodesSingleTerminatedEntry", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		if err := enc.Encode(buf, conformanceEntry(0)); err != nil {
+			t.Fatalf("failed to encode entry: %s", err)
+		}
+		out := buf.String()
+		if !strings.HasSuffix(out, "\n") {
+			t.Errorf("expected entry to be terminated by a newline: %q", out)
+		}
+		if n := strings.Count(strings.TrimSuffix(out, "\n"), "\n"); n != 0 {
+			t.Errorf("expected a single line but got %d line breaks: %q", n, out)
+		}
+	})
+
+	t.Run("EncodesMessageAndContext", func(t *testing.T) {
+		buf := bytes.NewBuffer(nil)
+		if err := enc.Encode(buf, conformanceEntry(0)); err != nil {
+			t.Fatalf("failed to encode entry: %s", err)
+		}
+		out := buf.String()
+		for _, s := range []string{"conformance message 0", "conformance_key", "conformance value", "conformance-component"} {
+			if !strings.Contains(out, s) {
+				t.Errorf("expected %q in entry: %q", s, out)
+			}
+		}
+	})
+
+	t.Run("IsSafeForConcurrentUse", func(t *testing.T) {
+		const workers = 16
+
+		var wg sync.WaitGroup
+		outputs := make([]string, workers)
+		errs := make([]error, workers)
+		for i := 0; i < workers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				buf := bytes.NewBuffer(nil)
+				errs[i] = enc.Encode(buf, conformanceEntry(i))
+				outputs[i] = buf.String()
+			}(i)
+		}
+		wg.Wait()
+
+		for i := 0; i < workers; i++ {
+			if errs[i] != nil {
+				t.Fatalf("failed to encode entry: %s", errs[i])
+			}
+			if msg := fmt.Sprintf("conformance message %d", i); !strings.Contains(outputs[i], msg) {
+				t.Errorf("expected %q in entry: %q", msg, outputs[i])
+			}
+		}
+	})
+}
+
+// conformanceEntry returns the entry i used by EncoderConformance
+func conformanceEntry(i int) log.Entry {
+	return log.Entry{
+		Timestamp: "2021-01-01T00:00:00Z",
+		Level:     "0",
+		Component: "conformance-component",
+		Message:   fmt.Sprintf("conformance message %d", i),
+		Fields: map[string]interface{}{
+			"conformance_key": "conformance value",
+			"index":           i,
+		},
+		Caller: "conformance.go:1",
+	}
+}
@@ -0,0 +1,21 @@
+package logtest_test
+
+import (
+	"testing"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/ViaQ/logerr/logtest"
+)
+
+func TestEncoderConformance_JSONEncoder(t *testing.T) {
+	logtest.EncoderConformance(t, log.JSONEncoder{})
+	logtest.EncoderConformance(t, log.JSONEncoder{StackTrace: true, ComponentKey: "logger"})
+}
+
+func TestEncoderConformance_LogfmtEncoder(t *testing.T) {
+	logtest.EncoderConformance(t, log.LogfmtEncoder{})
+}
+
+func TestEncoderConformance_ConsoleEncoder(t *testing.T) {
+	logtest.EncoderConformance(t, log.ConsoleEncoder{})
+}
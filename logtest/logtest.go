@@ -0,0 +1,79 @@
+package logtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// EqualEntries compares two JSON encoded log lines ignoring field order and
+// the keys in ignoreKeys (such as the timestamp). It returns whether the
+// entries are equal and, if they are not, a human readable diff.
+func EqualEntries(a, b []byte, ignoreKeys ...string) (bool, string) {
+	left, err := parseEntry(a)
+	if err != nil {
+		return false, fmt.Sprintf("failed to parse first entry: %s", err)
+	}
+	right, err := parseEntry(b)
+	if err != nil {
+		return false, fmt.Sprintf("failed to parse second entry: %s", err)
+	}
+
+	for _, k := range ignoreKeys {
+		delete(left, k)
+		delete(right, k)
+	}
+
+	keys := make([]string, 0, len(left)+len(right))
+	for k := range left {
+		keys = append(keys, k)
+	}
+	for k := range right {
+		if _, ok := left[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	var diff []string
+	for _, k := range keys {
+		lv, lok := left[k]
+		rv, rok := right[k]
+		switch {
+		case !rok:
+			diff = append(diff, fmt.Sprintf("- %q: %s", k, render(lv)))
+		case !lok:
+			diff = append(diff, fmt.Sprintf("+ %q: %s", k, render(rv)))
+		case !reflect.DeepEqual(lv, rv):
+			diff = append(diff,
+				fmt.Sprintf("- %q: %s", k, render(lv)),
+				fmt.Sprintf("+ %q: %s", k, render(rv)),
+			)
+		}
+	}
+
+	if len(diff) == 0 {
+		return true, ""
+	}
+	return false, strings.Join(diff, "\n")
+}
+
+// parseEntry decodes a single JSON object
+func parseEntry(b []byte) (map[string]interface{}, error) {
+	m := map[string]interface{}{}
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// render re-encodes a decoded value for display in a diff
+func render(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprintf("%v", v)
+	}
+	return string(b)
+}
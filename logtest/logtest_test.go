@@ -0,0 +1,48 @@
+package logtest_test
+
+import (
+	"testing"
+
+	"github.com/ViaQ/logerr/logtest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEqualEntries_IgnoresFieldOrder(t *testing.T) {
+	a := []byte(`{"_message":"hello","city":"Athens","_level":"0"}`)
+	b := []byte(`{"_level":"0","city":"Athens","_message":"hello"}`)
+
+	ok, diff := logtest.EqualEntries(a, b)
+	require.True(t, ok, diff)
+	require.Empty(t, diff)
+}
+
+func TestEqualEntries_IgnoresKeys(t *testing.T) {
+	a := []byte(`{"_ts":"2021-01-01T00:00:00Z","_message":"hello"}`)
+	b := []byte(`{"_ts":"2022-02-02T00:00:00Z","_message":"hello"}`)
+
+	ok, _ := logtest.EqualEntries(a, b)
+	require.False(t, ok)
+
+	ok, diff := logtest.EqualEntries(a, b, "_ts")
+	require.True(t, ok, diff)
+}
+
+func TestEqualEntries_ReportsDiff(t *testing.T) {
+	a := []byte(`{"_message":"hello","city":"Athens","removed":1}`)
+	b := []byte(`{"_message":"hello","city":"Berlin","added":true}`)
+
+	ok, diff := logtest.EqualEntries(a, b)
+	require.False(t, ok)
+	assert.Contains(t, diff, `- "city": "Athens"`)
+	assert.Contains(t, diff, `+ "city": "Berlin"`)
+	assert.Contains(t, diff, `- "removed": 1`)
+	assert.Contains(t, diff, `+ "added": true`)
+	assert.NotContains(t, diff, "_message")
+}
+
+func TestEqualEntries_InvalidJSON(t *testing.T) {
+	ok, diff := logtest.EqualEntries([]byte(`{`), []byte(`{}`))
+	require.False(t, ok)
+	require.Contains(t, diff, "failed to parse first entry")
+}
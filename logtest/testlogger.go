@@ -0,0 +1,37 @@
+package logtest
+
+import (
+	"strings"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/go-logr/logr"
+)
+
+// TB is the subset of testing.TB used to write log entries
+type TB interface {
+	Helper()
+	Log(args ...interface{})
+}
+
+// NewTestTLogger creates a logger that writes each entry encoded by e through
+// t.Log so that entries are shown inline with the test failures and nothing
+// is written to stdout. If e is nil, log.JSONEncoder is used. The logger
+// honors the verbosity set with log.SetLogLevel.
+func NewTestTLogger(t TB, e log.Encoder) logr.Logger {
+	if e == nil {
+		e = log.JSONEncoder{}
+	}
+	return log.NewLogger("", tWriter{t: t}, 0, e)
+}
+
+// tWriter writes to t.Log
+type tWriter struct {
+	t TB
+}
+
+// Write writes p as one t.Log call
+func (w tWriter) Write(p []byte) (int, error) {
+	w.t.Helper()
+	w.t.Log(strings.TrimSuffix(string(p), "\n"))
+	return len(p), nil
+}
@@ -0,0 +1,55 @@
+package logtest_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/ViaQ/logerr/log"
+	"github.com/ViaQ/logerr/logtest"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeT records the t.Log calls
+type fakeT struct {
+	logs []string
+}
+
+func (f *fakeT) Helper() {}
+
+func (f *fakeT) Log(args ...interface{}) {
+	f.logs = append(f.logs, fmt.Sprint(args...))
+}
+
+func TestNewTestTLogger_WritesThroughLog(t *testing.T) {
+	ft := &fakeT{}
+	logger := logtest.NewTestTLogger(ft, nil)
+
+	logger.Info("hello, world", "city", "Athens")
+	logger.Info("goodbye, world")
+
+	require.Len(t, ft.logs, 2)
+	require.Contains(t, ft.logs[0], `"city":"Athens"`)
+	require.Contains(t, ft.logs[1], "goodbye, world")
+	require.NotContains(t, ft.logs[0], "\n")
+}
+
+func TestNewTestTLogger_HonorsVerbosity(t *testing.T) {
+	log.SetLogLevel(0)
+
+	ft := &fakeT{}
+	logger := logtest.NewTestTLogger(ft, nil)
+
+	logger.V(1).Info("hidden")
+	require.Empty(t, ft.logs)
+
+	log.SetLogLevel(1)
+	defer log.SetLogLevel(0)
+
+	logger.V(1).Info("shown")
+	require.Len(t, ft.logs, 1)
+}
+
+func TestNewTestTLogger_WithTestingT(t *testing.T) {
+	logger := logtest.NewTestTLogger(t, nil)
+	logger.Info("hello from a real *testing.T")
+}